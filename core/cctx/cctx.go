@@ -1,8 +1,46 @@
 package cctx
 
+import (
+	"context"
+	"slices"
+)
+
 // Context context
-type Context struct{}
+type Context struct {
+	ctx           context.Context
+	authedPubkeys []string
+}
 
 func New() *Context {
 	return &Context{}
 }
+
+// WithContext new context with std context
+// ใช้ยกเลิก query ที่ค้างอยู่ เช่น เมื่อ client ส่ง CLOSE
+func (c *Context) WithContext(ctx context.Context) *Context {
+	return &Context{ctx: ctx, authedPubkeys: c.authedPubkeys}
+}
+
+// WithAuthedPubkeys new context with pubkey ที่ยืนยันตัวตนแล้ว (NIP-42)
+func (c *Context) WithAuthedPubkeys(pubkeys []string) *Context {
+	return &Context{ctx: c.ctx, authedPubkeys: pubkeys}
+}
+
+// AuthedPubkeys pubkey ที่ยืนยันตัวตนแล้วของการเชื่อมต่อ
+func (c *Context) AuthedPubkeys() []string {
+	return c.authedPubkeys
+}
+
+// IsAuthedPubkey check pubkey ยืนยันตัวตนแล้ว
+func (c *Context) IsAuthedPubkey(pubkey string) bool {
+	return slices.Contains(c.authedPubkeys, pubkey)
+}
+
+// Context get std context
+func (c *Context) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
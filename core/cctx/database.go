@@ -8,5 +8,9 @@ import (
 
 // GetDatabase get connection database
 func (c *Context) GetDatabase() *gorm.DB {
+	if c.ctx != nil {
+		return sql.Database.WithContext(c.ctx)
+	}
+
 	return sql.Database
 }
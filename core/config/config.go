@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -33,6 +34,14 @@ func (e Environment) Production() bool {
 	return e == Production
 }
 
+// ProcessingMode processing mode
+type ProcessingMode string
+
+const (
+	ProcessingOrdered  ProcessingMode = "ordered"
+	ProcessingParallel ProcessingMode = "parallel"
+)
+
 type DatabaseConfig struct {
 	Host         string        `mapstructure:"HOST"`
 	Port         int           `mapstructure:"PORT"`
@@ -58,33 +67,118 @@ type InfoLimitation struct {
 	RestrictedWrites bool `mapstructure:"RESTRICTED_WRITES"`
 }
 
+type InfoRetention struct {
+	Kinds []interface{} `mapstructure:"KINDS"` // kind หรือช่วง [from, to]
+	Time  *int64        `mapstructure:"TIME"`  // วินาที, 0 คือไม่จัดเก็บ
+	Count *int64        `mapstructure:"COUNT"`
+}
+
+type InfoFee struct {
+	Amount int64  `mapstructure:"AMOUNT"`
+	Unit   string `mapstructure:"UNIT"`
+	Period int64  `mapstructure:"PERIOD"`
+	Kinds  []int  `mapstructure:"KINDS"`
+}
+
+type InfoFees struct {
+	Admission    []*InfoFee `mapstructure:"ADMISSION"`
+	Subscription []*InfoFee `mapstructure:"SUBSCRIPTION"`
+	Publication  []*InfoFee `mapstructure:"PUBLICATION"`
+}
+
+// PubkeyClass กลุ่มของ pubkey สำหรับกำหนด proof of work
+type PubkeyClass string
+
+const (
+	PubkeyUnknown     PubkeyClass = "unknown"     // ยังไม่เคยมี event ในรีเลย์
+	PubkeyKnown       PubkeyClass = "known"       // มี event ในรีเลย์แล้ว
+	PubkeyAllowlisted PubkeyClass = "allowlisted" // อยู่ใน allowlist
+)
+
+type PowRule struct {
+	Kinds      []interface{} `mapstructure:"KINDS"` // kind หรือช่วง [from, to] ไม่กำหนดคือทุก kind
+	Class      PubkeyClass   `mapstructure:"CLASS"` // unknown, known, allowlisted ไม่กำหนดคือทุกกลุ่ม
+	Difficulty int           `mapstructure:"DIFFICULTY"`
+}
+
 type Configs struct {
 	Info struct {
-		Name          string          `mapstructure:"NAME"`
-		Description   string          `mapstructure:"DESCRIPTION"`
-		Pubkey        string          `mapstructure:"PUBKEY"`
-		Contact       string          `mapstructure:"CONTACT"`
-		SupportedNIPs []int           `mapstructure:"SUPPORTED_NIPS" json:"supported_nips"`
-		Software      string          `mapstructure:"SOFTWARE"`
-		Version       string          `mapstructure:"VERSION"`
-		Icon          string          `mapstructure:"ICON"`
-		Limitation    *InfoLimitation `mapstructure:"LIMITATION"`
+		Name           string           `mapstructure:"NAME"`
+		Description    string           `mapstructure:"DESCRIPTION"`
+		Banner         string           `mapstructure:"BANNER"`
+		Pubkey         string           `mapstructure:"PUBKEY"`
+		Contact        string           `mapstructure:"CONTACT"`
+		SupportedNIPs  []int            `mapstructure:"SUPPORTED_NIPS" json:"supported_nips"`
+		Software       string           `mapstructure:"SOFTWARE"`
+		Version        string           `mapstructure:"VERSION"`
+		Icon           string           `mapstructure:"ICON"`
+		PrivacyPolicy  string           `mapstructure:"PRIVACY_POLICY"`
+		TermsOfService string           `mapstructure:"TERMS_OF_SERVICE"`
+		Limitation     *InfoLimitation  `mapstructure:"LIMITATION"`
+		Retention      []*InfoRetention `mapstructure:"RETENTION"`
+		RelayCountries []string         `mapstructure:"RELAY_COUNTRIES"`
+		LanguageTags   []string         `mapstructure:"LANGUAGE_TAGS"`
+		Tags           []string         `mapstructure:"TAGS"`
+		PostingPolicy  string           `mapstructure:"POSTING_POLICY"`
+		PaymentsURL    string           `mapstructure:"PAYMENTS_URL"`
+		Fees           *InfoFees        `mapstructure:"FEES"`
 	} `mapstructure:"INFO"`
 
 	App struct {
-		AvailableStatus string      // สถานะปิด/เปิดระบบ [on/off]
-		Port            int         `mapstructure:"PORT"`
-		Environment     Environment `mapstructure:"ENVIRONMENT"`
-		RateLimit       struct {
-			Limit         int  `mapstructure:"LIMIT"`
-			Burst         int  `mapstructure:"BURST"`
-			Enable        bool `mapstructure:"ENABLE"`
-			BlockIPEnable bool `mapstructure:"BLOCK_IP_ENABLE"`
+		AvailableStatus     string        // สถานะปิด/เปิดระบบ [on/off]
+		Port                int           `mapstructure:"PORT"`
+		ServiceURL          string        `mapstructure:"SERVICE_URL"`           // url ของรีเลย์ เช่น wss://relay.example.com
+		ShutdownTimeout     time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`      // เวลารองานที่ค้างอยู่ก่อนปิดระบบ
+		ShutdownGracePeriod time.Duration `mapstructure:"SHUTDOWN_GRACE_PERIOD"` // เวลาหลัง readiness ไม่พร้อมก่อนหยุดรับ connection
+		TemplateDir         string        `mapstructure:"TEMPLATE_DIR"`          // โฟลเดอร์ template หน้าเว็บ (index.html) ใช้แทน template ที่ฝังมา
+		Environment         Environment   `mapstructure:"ENVIRONMENT"`
+		RateLimit           struct {
+			Limit              int           `mapstructure:"LIMIT"`
+			Burst              int           `mapstructure:"BURST"`
+			Enable             bool          `mapstructure:"ENABLE"`
+			BlockIPEnable      bool          `mapstructure:"BLOCK_IP_ENABLE"`
+			BlockIPDuration    time.Duration `mapstructure:"BLOCK_IP_DURATION"`     // ระยะเวลา block ครั้งแรก จะเพิ่มเป็น 2 เท่าทุกครั้งที่โดนซ้ำ
+			BlockIPMaxDuration time.Duration `mapstructure:"BLOCK_IP_MAX_DURATION"` // ระยะเวลา block สูงสุด
 		} `mapstructure:"RATELIMIT"`
+		Proxy struct {
+			TrustedCIDRs []string `mapstructure:"TRUSTED_CIDRS"` // proxy ที่เชื่อถือได้ เช่น 10.0.0.0/8
+			Header       string   `mapstructure:"HEADER"`        // X-Forwarded-For, X-Real-IP, CF-Connecting-IP, PROXY
+		} `mapstructure:"PROXY"`
+		Favicon struct {
+			Path            string        `mapstructure:"PATH"`             // ไฟล์ favicon ในเครื่อง (ใช้แทน icon url)
+			CacheDir        string        `mapstructure:"CACHE_DIR"`        // โฟลเดอร์ cache favicon ที่โหลดจาก icon url
+			RefreshInterval time.Duration `mapstructure:"REFRESH_INTERVAL"` // รอบการโหลด icon url ใหม่
+			Timeout         time.Duration `mapstructure:"TIMEOUT"`
+			MaxSize         int64         `mapstructure:"MAX_SIZE"` // bytes
+		} `mapstructure:"FAVICON"`
+		Metrics struct {
+			Enable bool `mapstructure:"ENABLE"` // เปิด endpoint /metrics
+		} `mapstructure:"METRICS"`
+		Admin struct {
+			Token   string   `mapstructure:"TOKEN"`   // token สำหรับ admin api (ไม่กำหนดคือปิดใช้งาน)
+			Pubkeys []string `mapstructure:"PUBKEYS"` // pubkey (hex) ที่ใช้ management api (NIP-86) ได้
+		} `mapstructure:"ADMIN"`
+		Pow struct {
+			Rules     []*PowRule `mapstructure:"RULES"`     // ใช้กฎแรกที่ตรง ถ้าไม่ตรงใช้ MIN_POW_DIFFICULTY
+			Allowlist []string   `mapstructure:"ALLOWLIST"` // pubkey (hex) กลุ่ม allowlisted
+			Adaptive  struct {
+				Enable    bool          `mapstructure:"ENABLE"`
+				Threshold float64       `mapstructure:"THRESHOLD"` // จำนวน event ต่อวินาทีที่เริ่มเพิ่มความยาก
+				Window    time.Duration `mapstructure:"WINDOW"`    // ช่วงเวลาที่ใช้วัดอัตรา event
+				Step      int           `mapstructure:"STEP"`      // bit ที่เพิ่มต่อทุกๆ threshold ที่เกิน
+				Max       int           `mapstructure:"MAX"`       // ความยากสูงสุด
+			} `mapstructure:"ADAPTIVE"`
+		} `mapstructure:"POW"`
+		Processing struct {
+			Mode           ProcessingMode `mapstructure:"MODE"`            // ordered, parallel
+			ReqParallelism int            `mapstructure:"REQ_PARALLELISM"` // จำนวน REQ ที่ประมวลผลพร้อมกันได้ต่อการเชื่อมต่อ (mode parallel)
+			MaxWorkers     int            `mapstructure:"MAX_WORKERS"`     // จำนวนข้อความที่ประมวลผลพร้อมกันได้ทั้งรีเลย์
+		} `mapstructure:"PROCESSING"`
 	} `mapstructure:"APP"`
 
 	Database struct {
-		RelaySQL DatabaseConfig `mapstructure:"RELAY_SQL"`
+		RelaySQL       DatabaseConfig `mapstructure:"RELAY_SQL"`
+		SearchLanguage string         `mapstructure:"SEARCH_LANGUAGE"` // text search config ของ postgres รองรับเฉพาะ simple
 	} `mapstructure:"DATABASE"`
 
 	Blacklist struct {
@@ -97,6 +191,19 @@ type Configs struct {
 			Words   []string `mapstructure:"WORDS"`
 		} `mapstructure:"BLOCK_WORDS"`
 	} `mapstructure:"BLACKLIST"`
+
+	Allowlist struct {
+		Pubkeys []string `mapstructure:"PUBKEYS"` // pubkey (hex) ที่เพิ่มเข้า allowlist ตอนเริ่มระบบ
+		Inbox   bool     `mapstructure:"INBOX"`   // รับ event ที่ p-tag pubkey ใน allowlist (เมื่อเปิด RESTRICTED_WRITES)
+	} `mapstructure:"ALLOWLIST"`
+
+	WebOfTrust struct {
+		Enable       bool     `mapstructure:"ENABLE"`        // รับ event เฉพาะ pubkey ใน web of trust
+		Roots        []string `mapstructure:"ROOTS"`         // pubkey (hex) เริ่มต้นของ follow graph
+		Depth        int      `mapstructure:"DEPTH"`         // จำนวน hop จาก root
+		MinFollowers int      `mapstructure:"MIN_FOLLOWERS"` // จำนวนผู้ติดตามขั้นต่ำจาก hop ก่อนหน้า (ไม่ใช้กับ hop แรก)
+		Schedule     string   `mapstructure:"SCHEDULE"`      // รอบการคำนวณใหม่ (cron)
+	} `mapstructure:"WEB_OF_TRUST"`
 }
 
 // InitConfig init config
@@ -110,6 +217,23 @@ func InitConfig() error {
 	// แปลง _ underscore เป็น . dot
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
+	// ค่าเริ่มต้น
+	v.SetDefault("APP.SHUTDOWN_TIMEOUT", 10*time.Second)
+	v.SetDefault("APP.SHUTDOWN_GRACE_PERIOD", 5*time.Second)
+	v.SetDefault("APP.FAVICON.REFRESH_INTERVAL", 24*time.Hour)
+	v.SetDefault("APP.FAVICON.TIMEOUT", 10*time.Second)
+	v.SetDefault("APP.FAVICON.MAX_SIZE", 1024*1024)
+	v.SetDefault("APP.RATELIMIT.BLOCK_IP_DURATION", 10*time.Minute)
+	v.SetDefault("APP.RATELIMIT.BLOCK_IP_MAX_DURATION", 24*time.Hour)
+	v.SetDefault("APP.POW.ADAPTIVE.WINDOW", 10*time.Second)
+	v.SetDefault("APP.POW.ADAPTIVE.STEP", 4)
+	v.SetDefault("APP.POW.ADAPTIVE.MAX", 32)
+	v.SetDefault("INFO.LIMITATION.MAX_SUBID_LENGTH", 64)
+	v.SetDefault("DATABASE.SEARCH_LANGUAGE", "simple")
+	v.SetDefault("WEB_OF_TRUST.DEPTH", 2)
+	v.SetDefault("WEB_OF_TRUST.MIN_FOLLOWERS", 1)
+	v.SetDefault("WEB_OF_TRUST.SCHEDULE", "0 * * * *")
+
 	if err := v.ReadInConfig(); err != nil {
 		logger.Log.Errorf("read config file error: %s", err)
 		return err
@@ -120,6 +244,13 @@ func InitConfig() error {
 		return err
 	}
 
+	// live search ตัดคำแบบ simple ภาษาอื่น (stemming, stopwords) จะได้ผลไม่ตรงกับการค้นจากฐานข้อมูล
+	if CF.Database.SearchLanguage != "simple" {
+		err := fmt.Errorf("unsupported search language: %s (only simple is supported)", CF.Database.SearchLanguage)
+		logger.Log.Errorf("binding config error: %s", err)
+		return err
+	}
+
 	v.OnConfigChange(func(e fsnotify.Event) {
 		logger.Log.Infof("config file changed: %s", e.Name)
 		if err := v.Unmarshal(CF); err != nil {
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "reraw"
+
+var (
+	// ConnectedClients จำนวน client ที่เชื่อมต่ออยู่
+	ConnectedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connected_clients",
+		Help:      "Number of connected websocket clients.",
+	})
+
+	// ActiveSubscriptions จำนวน subscription ที่เปิดอยู่
+	ActiveSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_subscriptions",
+		Help:      "Number of open REQ subscriptions.",
+	})
+
+	// Messages จำนวนข้อความแยกตาม command
+	Messages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_total",
+		Help:      "Number of messages received by command.",
+	}, []string{"command"})
+
+	// OKResponses จำนวน OK แยกตามผลลัพธ์และ prefix ของเหตุผล
+	OKResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ok_responses_total",
+		Help:      "Number of OK responses by result and reason prefix.",
+	}, []string{"accepted", "reason"})
+
+	// RateLimitDisconnects จำนวนการตัดการเชื่อมต่อจาก rate limit
+	RateLimitDisconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_disconnects_total",
+		Help:      "Number of clients disconnected by the rate limiter.",
+	})
+
+	// QueryDuration เวลาที่ใช้ query แยกตาม method ของ eventstore
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "eventstore_query_duration_seconds",
+		Help:      "Eventstore query latency by repository method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// CronDuration เวลาที่ใช้รัน cron job
+	CronDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cron_job_duration_seconds",
+		Help:      "Cron job duration by job.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+)
+
+// commands command ที่รู้จัก ป้องกัน label ไม่จำกัด
+var commands = map[string]bool{
+	"EVENT": true,
+	"REQ":   true,
+	"CLOSE": true,
+	"COUNT": true,
+	"AUTH":  true,
+}
+
+// reasons prefix ของเหตุผลตาม NIP-01
+var reasons = map[string]bool{
+	"duplicate":     true,
+	"pow":           true,
+	"blocked":       true,
+	"rate-limited":  true,
+	"invalid":       true,
+	"restricted":    true,
+	"mute":          true,
+	"error":         true,
+	"auth-required": true,
+}
+
+// ObserveMessage นับข้อความตาม command
+func ObserveMessage(cmd string) {
+	if !commands[cmd] {
+		cmd = "unknown"
+	}
+
+	Messages.WithLabelValues(cmd).Inc()
+}
+
+// ObserveOK นับ OK ตามผลลัพธ์และ prefix ของเหตุผล
+func ObserveOK(accepted bool, message string) {
+	reason := "none"
+	if prefix, _, found := strings.Cut(message, ":"); found {
+		reason = "other"
+		if reasons[prefix] {
+			reason = prefix
+		}
+	}
+
+	result := "false"
+	if accepted {
+		result = "true"
+	}
+
+	OKResponses.WithLabelValues(result, reason).Inc()
+}
+
+// ObserveQuery บันทึกเวลา query ใช้กับ defer
+//
+//	defer metrics.ObserveQuery("FindAll", time.Now())
+func ObserveQuery(method string, start time.Time) {
+	QueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCron บันทึกเวลารัน cron job
+func ObserveCron(job string, start time.Time) {
+	CronDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+}
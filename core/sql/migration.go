@@ -3,6 +3,7 @@ package sql
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,6 +14,8 @@ import (
 	"github.com/saveblush/reraw-relay/models"
 )
 
+var migrated atomic.Bool
+
 func createDatabase(cf *Configuration) error {
 	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%d sslmode=disable TimeZone=%s",
 		cf.Username,
@@ -71,7 +74,28 @@ func Migration(db *gorm.DB) error {
  		);
 	`)
 
-	sqls = append(sqls, `CREATE EXTENSION IF NOT EXISTS pg_trgm;`)
+	// รวม register ของ HyperLogLog (NIP-45) โดยเลือกค่าที่มากกว่าในแต่ละตำแหน่ง
+	sqls = append(sqls, `
+		CREATE OR REPLACE FUNCTION hll_merge(a bytea, b bytea)
+			RETURNS bytea
+			LANGUAGE plpgsql
+			IMMUTABLE
+		AS $$
+		DECLARE
+			r bytea := a;
+		BEGIN
+			IF a IS NULL THEN
+				RETURN b;
+			END IF;
+			FOR i IN 0..length(b) - 1 LOOP
+				IF get_byte(b, i) > get_byte(r, i) THEN
+					r := set_byte(r, i, get_byte(b, i));
+				END IF;
+			END LOOP;
+			RETURN r;
+		END;
+		$$;
+	`)
 
 	// index events
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_id ON events (id);`)
@@ -79,7 +103,7 @@ func Migration(db *gorm.DB) error {
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_created_at ON events (created_at DESC);`)
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);`)
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_kind ON events (kind);`)
-	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_content ON events USING gin (content gin_trgm_ops);`)
+	sqls = append(sqls, `DROP INDEX IF EXISTS idx_events_content;`)
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_tagvalues ON events USING gin (tagvalues);`)
 	sqls = append(sqls, `CREATE INDEX IF NOT EXISTS idx_events_expiration ON events (expiration);`)
 
@@ -91,7 +115,33 @@ func Migration(db *gorm.DB) error {
 		}
 	}
 
-	db.AutoMigrate(&models.Blacklist{})
+	err := migrateSearchVector(db)
+	if err != nil {
+		logger.Log.Errorf("db migration search vector error: %s", err)
+		return err
+	}
+
+	err = db.AutoMigrate(
+		&models.Blacklist{},
+		&models.Allowlist{},
+		&models.AllowKind{},
+		&models.BlockIP{},
+		&models.HLLCount{},
+		&models.Deletion{},
+		&models.Vanish{},
+		&models.Setting{},
+	)
+	if err != nil {
+		logger.Log.Errorf("db auto migration error: %s", err)
+		return err
+	}
+
+	migrated.Store(true)
 
 	return nil
 }
+
+// IsMigrated check migration สำเร็จแล้ว
+func IsMigrated() bool {
+	return migrated.Load()
+}
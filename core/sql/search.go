@@ -0,0 +1,64 @@
+package sql
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+)
+
+// DefaultSearchLanguage text search config เริ่มต้นของ postgres
+const DefaultSearchLanguage = "simple"
+
+// SearchLanguage text search config ที่ใช้ค้นหา (NIP-50)
+// รองรับเฉพาะ simple เพื่อให้ตรงกับการตัดคำของ live search (models.Search.Match)
+func SearchLanguage() string {
+	language := config.CF.Database.SearchLanguage
+	if language != DefaultSearchLanguage {
+		return DefaultSearchLanguage
+	}
+
+	return language
+}
+
+// migrateSearchVector สร้าง column search_vector (NIP-50) ตาม SEARCH_LANGUAGE
+// การเพิ่ม column จะเขียนตาราง events ใหม่ทั้งหมด จึงทำเฉพาะเมื่อยังไม่มี column
+// หรือ language ของ column ไม่ตรงกับ config (สร้างใหม่ด้วย language ใหม่)
+func migrateSearchVector(db *gorm.DB) error {
+	language := SearchLanguage()
+
+	var expr string
+	err := db.Raw(`
+		SELECT pg_get_expr(d.adbin, d.adrelid)
+		FROM pg_attribute a
+		JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = 'events'::regclass AND a.attname = 'search_vector' AND NOT a.attisdropped
+	`).Scan(&expr).Error
+	if err != nil {
+		return err
+	}
+
+	if expr != "" && !strings.Contains(expr, "'"+language+"'::regconfig") {
+		logger.Log.Warnf("search language changed to %s (was %s), rebuilding events.search_vector, this may take a while", language, expr)
+		err := db.Exec(`ALTER TABLE events DROP COLUMN search_vector`).Error
+		if err != nil {
+			return err
+		}
+		expr = ""
+	}
+
+	if expr == "" {
+		logger.Log.Infof("adding events.search_vector (%s), this may take a while", language)
+		err := db.Exec(`
+			ALTER TABLE events ADD COLUMN search_vector tsvector
+				GENERATED ALWAYS AS (to_tsvector('` + language + `'::regconfig, coalesce(content, ''))) STORED
+		`).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_events_search_vector ON events USING gin (search_vector)`).Error
+}
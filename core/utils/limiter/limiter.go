@@ -2,12 +2,18 @@ package limiter
 
 import (
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
 type IPRateLimiter struct {
-	ips   map[string]*rate.Limiter
+	ips   map[string]*visitor
 	mu    *sync.Mutex
 	limit rate.Limit
 	burst int
@@ -16,7 +22,7 @@ type IPRateLimiter struct {
 // NewIPRateLimiter new IP rate limiter
 func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
 	return &IPRateLimiter{
-		ips:   make(map[string]*rate.Limiter),
+		ips:   make(map[string]*visitor),
 		mu:    &sync.Mutex{},
 		limit: r,
 		burst: b,
@@ -26,24 +32,26 @@ func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
 // GetLimiter get limiter
 func (r *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	limiter, exists := r.ips[ip]
+	v, exists := r.ips[ip]
 	if !exists {
-		r.mu.Unlock()
-		return r.addIP(ip)
+		v = &visitor{limiter: rate.NewLimiter(r.limit, r.burst)}
+		r.ips[ip] = v
 	}
-	r.mu.Unlock()
+	v.lastSeen = time.Now()
 
-	return limiter
+	return v.limiter
 }
 
-// addIP add IP
-func (r *IPRateLimiter) addIP(ip string) *rate.Limiter {
+// Cleanup ลบ limiter ของ IP ที่ไม่มีการใช้งานนานกว่า idle
+func (r *IPRateLimiter) Cleanup(idle time.Duration) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	limiter := rate.NewLimiter(r.limit, r.burst)
-	r.ips[ip] = limiter
-
-	return limiter
+	for ip, v := range r.ips {
+		if time.Since(v.lastSeen) > idle {
+			delete(r.ips, ip)
+		}
+	}
 }
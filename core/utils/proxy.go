@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ProxyProtocol ใช้ PROXY protocol จาก load balancer แทนการอ่าน header
+const ProxyProtocol = "PROXY"
+
+type proxyConfig struct {
+	networks []*net.IPNet
+	header   string
+}
+
+var trustedProxy atomic.Pointer[proxyConfig]
+
+// SetTrustedProxies set trusted proxy cidrs and header for client ip
+func SetTrustedProxies(cidrs []string, header string) error {
+	proxy := &proxyConfig{}
+	for _, v := range cidrs {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %s: %s", v, err)
+		}
+		proxy.networks = append(proxy.networks, network)
+	}
+
+	if strings.EqualFold(header, ProxyProtocol) {
+		proxy.header = ProxyProtocol
+	} else if header != "" {
+		proxy.header = http.CanonicalHeaderKey(header)
+	}
+
+	trustedProxy.Store(proxy)
+
+	return nil
+}
+
+// IsTrustedProxyAddr check address is trusted proxy
+func IsTrustedProxyAddr(addr net.Addr) bool {
+	proxy := trustedProxy.Load()
+	if proxy == nil {
+		return false
+	}
+
+	return proxy.trusted(hostIP(addr.String()))
+}
+
+// IsTrustedProxyRequest check request มาจาก proxy ที่เชื่อถือได้
+func IsTrustedProxyRequest(r *http.Request) bool {
+	proxy := trustedProxy.Load()
+	if proxy == nil {
+		return false
+	}
+
+	return proxy.trusted(hostIP(r.RemoteAddr))
+}
+
+func (p *proxyConfig) trusted(ip string) bool {
+	v := net.ParseIP(ip)
+	if v == nil {
+		return false
+	}
+
+	for _, network := range p.networks {
+		if network.Contains(v) {
+			return true
+		}
+	}
+
+	return false
+}
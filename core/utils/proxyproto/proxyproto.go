@@ -0,0 +1,197 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	signatureV1 = []byte("PROXY ")
+	signatureV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+var (
+	errMissingHeader = errors.New("proxy protocol: missing header")
+	errInvalidHeader = errors.New("proxy protocol: invalid header")
+)
+
+// Listener listener ที่อ่าน PROXY protocol (v1, v2) จาก proxy ที่เชื่อถือได้
+type Listener struct {
+	net.Listener
+	trusted func(addr net.Addr) bool
+	timeout time.Duration
+}
+
+// NewListener new listener
+func NewListener(l net.Listener, trusted func(addr net.Addr) bool, timeout time.Duration) *Listener {
+	return &Listener{
+		Listener: l,
+		trusted:  trusted,
+		timeout:  timeout,
+	}
+}
+
+// Accept accept connection
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		trusted: l.trusted != nil && l.trusted(conn.RemoteAddr()),
+		timeout: l.timeout,
+	}, nil
+}
+
+// Conn connection ที่อ่าน header ของ PROXY protocol ก่อนข้อมูลแรก
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	trusted bool
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// init อ่าน header ครั้งแรกที่มีการใช้งาน connection
+func (c *Conn) init() {
+	c.once.Do(func() {
+		if !c.trusted {
+			return
+		}
+
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		c.remote, c.err = ReadHeader(c.reader)
+	})
+}
+
+// Read read data
+func (c *Conn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr address ของ client จาก header (ถ้ามี)
+func (c *Conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// ReadHeader อ่าน header ของ PROXY protocol
+// คืนค่า address เป็น nil กรณี proxy แจ้งว่าไม่มีข้อมูลต้นทาง (UNKNOWN/LOCAL)
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(signatureV1))
+	if err != nil {
+		return nil, errMissingHeader
+	}
+	if bytes.Equal(b, signatureV1) {
+		return readHeaderV1(r)
+	}
+
+	b, err = r.Peek(len(signatureV2))
+	if err != nil || !bytes.Equal(b, signatureV2) {
+		return nil, errMissingHeader
+	}
+
+	return readHeaderV2(r)
+}
+
+// readHeaderV1 PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, errInvalidHeader
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errInvalidHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errInvalidHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, errInvalidHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, errInvalidHeader
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, errInvalidHeader
+	}
+
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, errInvalidHeader
+	}
+
+	// command LOCAL
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if length < 12 {
+			return nil, errInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+
+	case 2: // AF_INET6
+		if length < 36 {
+			return nil, errInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+
+	return nil, nil
+}
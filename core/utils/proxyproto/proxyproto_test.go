@@ -0,0 +1,50 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadHeaderV1(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	addr, err := ReadHeader(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", addr.String())
+
+	rest, _ := r.ReadString('\n')
+	assert.Equal(t, "GET / HTTP/1.1\r\n", rest)
+
+	addr, err = ReadHeader(bufio.NewReader(bytes.NewBufferString("PROXY UNKNOWN\r\n")))
+	assert.NoError(t, err)
+	assert.Nil(t, addr)
+
+	_, err = ReadHeader(bufio.NewReader(bytes.NewBufferString("GET / HTTP/1.1\r\n")))
+	assert.Error(t, err)
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	payload := make([]byte, 12)
+	copy(payload[0:4], []byte{203, 0, 113, 7})
+	copy(payload[4:8], []byte{10, 0, 0, 1})
+	binary.BigEndian.PutUint16(payload[8:10], 56324)
+	binary.BigEndian.PutUint16(payload[10:12], 443)
+
+	var b bytes.Buffer
+	b.Write(signatureV2)
+	b.Write([]byte{0x21, 0x11, 0x00, byte(len(payload))})
+	b.Write(payload)
+	b.WriteString("hello")
+
+	r := bufio.NewReader(&b)
+	addr, err := ReadHeader(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", addr.String())
+
+	rest := make([]byte, 5)
+	_, _ = r.Read(rest)
+	assert.Equal(t, "hello", string(rest))
+}
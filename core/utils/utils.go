@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -11,24 +13,83 @@ func Pointer[Value any](v Value) *Value {
 }
 
 // GetIP get the client's ip address
+// จะอ่าน ip จาก header ที่กำหนดเฉพาะเมื่อ request มาจาก proxy ที่เชื่อถือได้เท่านั้น
 func GetIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ip := strings.Split(xff, ",")[0]
-		if ip != "" {
-			return ip
-		}
+	remoteIP := hostIP(r.RemoteAddr)
+
+	proxy := trustedProxy.Load()
+	if proxy == nil || proxy.header == "" || proxy.header == ProxyProtocol || !proxy.trusted(remoteIP) {
+		return remoteIP
 	}
 
-	remoteAddr := r.RemoteAddr
-	if idx := strings.LastIndex(remoteAddr, ":"); idx != -1 {
-		return remoteAddr[:idx]
+	switch proxy.header {
+	case "X-Forwarded-For":
+		// ไล่จากขวาไปซ้าย ip แรกที่ไม่ใช่ proxy ที่เชื่อถือได้คือ ip ของ client
+		ips := strings.Split(r.Header.Get(proxy.header), ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(ips[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if !proxy.trusted(ip) || i == 0 {
+				return ip
+			}
+		}
+
+	default:
+		ip := strings.TrimSpace(r.Header.Get(proxy.header))
+		if net.ParseIP(ip) != nil {
+			return ip
+		}
 	}
 
-	return remoteAddr
+	return remoteIP
 }
 
 // GetUserAgent get the client's user-agent
 func GetUserAgent(r *http.Request) string {
 	return r.Header.Get("User-Agent")
 }
+
+// IsSameRelayURL check relay url is the same
+// ไม่สนใจ scheme (ws/wss), ตัวพิมพ์ของ host และ / ท้าย path
+func IsSameRelayURL(a, b string) bool {
+	ua, err := url.Parse(strings.TrimSpace(a))
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(strings.TrimSpace(b))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Host, ub.Host) &&
+		strings.TrimRight(ua.Path, "/") == strings.TrimRight(ub.Path, "/")
+}
+
+// hostIP ตัด port ออกจาก address
+func hostIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+// IsHex64 check s เป็น hex ตัวพิมพ์เล็กยาว 64 ตัวอักษร เช่น pubkey หรือ event id
+func IsHex64(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIP(t *testing.T) {
+	newRequest := func(remoteAddr string, header map[string]string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	assert.NoError(t, SetTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"}, "X-Forwarded-For"))
+
+	// ไม่ใช่ proxy ที่เชื่อถือได้ ไม่สนใจ header
+	r := newRequest("198.51.100.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"})
+	assert.Equal(t, "198.51.100.1", GetIP(r))
+
+	// ข้าม proxy ที่เชื่อถือได้จากขวาไปซ้าย
+	r = newRequest("10.0.0.2:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9, 10.0.0.5"})
+	assert.Equal(t, "203.0.113.9", GetIP(r))
+
+	r = newRequest("127.0.0.1:1234", nil)
+	assert.Equal(t, "127.0.0.1", GetIP(r))
+	assert.True(t, IsTrustedProxyRequest(r))
+	assert.False(t, IsTrustedProxyRequest(newRequest("198.51.100.1:1234", nil)))
+
+	assert.NoError(t, SetTrustedProxies([]string{"10.0.0.0/8"}, "CF-Connecting-IP"))
+	r = newRequest("10.0.0.2:1234", map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Forwarded-For": "1.2.3.4"})
+	assert.Equal(t, "203.0.113.9", GetIP(r))
+
+	assert.Error(t, SetTrustedProxies([]string{"10.0.0.0/99"}, ""))
+}
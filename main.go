@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,7 +14,9 @@ import (
 
 	"github.com/saveblush/reraw-relay/core/config"
 	"github.com/saveblush/reraw-relay/core/sql"
+	"github.com/saveblush/reraw-relay/core/utils"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/core/utils/proxyproto"
 	"github.com/saveblush/reraw-relay/pgk/cron"
 	"github.com/saveblush/reraw-relay/relay"
 )
@@ -30,6 +33,12 @@ func main() {
 		logger.Log.Panicf("init configuration error: %s", err)
 	}
 
+	// Init trusted proxy
+	err = utils.SetTrustedProxies(config.CF.App.Proxy.TrustedCIDRs, config.CF.App.Proxy.Header)
+	if err != nil {
+		logger.Log.Panicf("init trusted proxy error: %s", err)
+	}
+
 	// Init connection database
 	cfdb := &sql.Configuration{
 		Host:         config.CF.Database.RelaySQL.Host,
@@ -55,7 +64,10 @@ func main() {
 	}
 
 	// Migration db
-	_ = sql.Migration(sql.Database)
+	err = sql.Migration(sql.Database)
+	if err != nil {
+		logger.Log.Errorf("migration db error: %s", err)
+	}
 
 	// Cron
 	cron := cron.NewService()
@@ -63,6 +75,12 @@ func main() {
 
 	// Init relay
 	rl := relay.NewRelay()
+	rl.AddReadyCheck("cron", func(ctx context.Context) error {
+		if !cron.Running() {
+			return errors.New("cron is not running")
+		}
+		return nil
+	})
 	handler := rl.Serve()
 
 	// Start app
@@ -73,8 +91,16 @@ func main() {
 	}
 	server.SetKeepAlivesEnabled(true)
 
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Log.Panicf("App listen error: %s", err)
+	}
+	if config.CF.App.Proxy.Header == utils.ProxyProtocol {
+		ln = proxyproto.NewListener(ln, utils.IsTrustedProxyAddr, 5*time.Second)
+	}
+
 	go func() {
-		err = server.ListenAndServe()
+		err := server.Serve(ln)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Log.Panicf("App start error: %s", err)
 		}
@@ -86,24 +112,44 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
-	// Close relay
-	go rl.CloseRelay()
+	// แจ้ง readiness ไม่พร้อม แล้วรอให้ load balancer หยุดส่ง connection ใหม่ก่อนปิด listener
+	rl.BeginDrain()
+	if grace := config.CF.App.ShutdownGracePeriod; grace > 0 {
+		logger.Log.Infof("Relay not ready, waiting %s before shutdown", grace)
+		time.Sleep(grace)
+	}
+
+	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), config.CF.App.ShutdownTimeout)
+	defer shutdownRelease()
+
+	// Stop accepting connections
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Log.Errorf("App shutdown error: %s", err)
+	}
+	logger.Log.Info("Server stopped accepting connections")
+
+	// Drain relay
+	err = rl.Drain(shutdownCtx)
+	if err != nil {
+		logger.Log.Errorf("Relay drain error: %s", err)
+	}
 	logger.Log.Info("Relay closed")
 
 	// Close cron
-	go cron.Stop()
-	logger.Log.Info("Cron closed")
+	select {
+	case <-cron.Stop().Done():
+		logger.Log.Info("Cron closed")
+	case <-shutdownCtx.Done():
+		logger.Log.Warn("Cron close timeout")
+	}
 
 	// Close db
-	go sql.CloseConnection(sql.Database)
-	logger.Log.Info("Database connection closed")
-
-	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 5*time.Second)
-	defer shutdownRelease()
-
-	err = server.Shutdown(shutdownCtx)
+	err = sql.CloseConnection(sql.Database)
 	if err != nil {
-		logger.Log.Panicf("App shutdown error: %s", err)
+		logger.Log.Errorf("Database close error: %s", err)
 	}
+	logger.Log.Info("Database connection closed")
+
 	logger.Log.Info("Gracefully shutting down")
 }
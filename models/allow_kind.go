@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// AllowKind kind ที่รับ ถ้ามีอย่างน้อยหนึ่งรายการจะรับเฉพาะ kind ในรายการ
+type AllowKind struct {
+	gorm.Model
+	Kind   int    `json:"kind" gorm:"uniqueIndex"`
+	Reason string `json:"reason"`
+}
+
+func (AllowKind) TableName() string {
+	return "allow_kinds"
+}
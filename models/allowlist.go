@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// Allowlist pubkey ที่เขียน event ได้เมื่อเปิด restricted writes
+type Allowlist struct {
+	gorm.Model
+	Pubkey string `json:"pubkey" gorm:"type:varchar(64);uniqueIndex"`
+	Reason string `json:"reason"`
+}
+
+func (Allowlist) TableName() string {
+	return "allowlists"
+}
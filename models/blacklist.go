@@ -2,9 +2,14 @@ package models
 
 import "gorm.io/gorm"
 
+// Blacklist รายการที่ถูกแบน
+// แบน pubkey, event id หรือ kind อย่างใดอย่างหนึ่งต่อรายการ
 type Blacklist struct {
 	gorm.Model
-	Pubkey string `json:"pubkey" gorm:"type:varchar(64)"`
+	Pubkey  string `json:"pubkey" gorm:"type:varchar(64)"`
+	EventID string `json:"event_id" gorm:"type:varchar(64);index"`
+	Kind    *int   `json:"kind" gorm:"index"`
+	Reason  string `json:"reason"`
 }
 
 func (Blacklist) TableName() string {
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BlockIP struct {
+	gorm.Model
+	IP        string    `json:"ip" gorm:"type:varchar(64);uniqueIndex"`
+	Reason    string    `json:"reason"`
+	Count     int       `json:"count"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+func (BlockIP) TableName() string {
+	return "block_ips"
+}
+
+// Active check block is not expired
+func (b *BlockIP) Active() bool {
+	return time.Now().Before(b.ExpiresAt)
+}
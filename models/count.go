@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// CountResult ผลลัพธ์ของ COUNT (NIP-45)
+type CountResult struct {
+	Count       int64  `json:"count"`
+	Approximate bool   `json:"approximate,omitempty"`
+	HLL         string `json:"hll,omitempty"`
+}
+
+// HLLCount register ของ HyperLogLog ต่อรูปแบบ filter (NIP-45)
+// key อยู่ในรูปแบบ <kind>:<tag>:<value> เช่น 7:e:<event id>
+type HLLCount struct {
+	Key        string    `gorm:"type:varchar(160);primaryKey"`
+	Registers  []byte    `gorm:"type:bytea;not null"`
+	Backfilled bool      `gorm:"not null;default:false"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (HLLCount) TableName() string {
+	return "hll_counts"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Deletion tombstone ของคำขอลบ event (NIP-09)
+// อ้างอิงด้วย event id หรือ coordinate (kind:pubkey:d) ที่ created_at ไม่เกิน Until
+type Deletion struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"-"`
+	DeletionID string    `json:"deletion_id" gorm:"type:varchar(64);index"`
+	Pubkey     string    `json:"pubkey" gorm:"type:varchar(64);index:idx_deletions_pubkey_event_id;index:idx_deletions_pubkey_address"`
+	EventID    string    `json:"event_id" gorm:"type:varchar(64);index:idx_deletions_pubkey_event_id"`
+	Address    string    `json:"address" gorm:"type:text;index:idx_deletions_pubkey_address"`
+	Until      Timestamp `json:"until" gorm:"type:integer"`
+}
+
+func (Deletion) TableName() string {
+	return "deletions"
+}
@@ -85,6 +85,19 @@ func (evt *Event) hash() [32]byte {
 	return sha256.Sum256([]byte(evt.Serialize()))
 }
 
+// Address coordinate ของ event ที่แทนที่ได้ (kind:pubkey:d)
+// return ค่าว่างสำหรับ event ทั่วไป
+func (evt *Event) Address() string {
+	switch {
+	case evt.Kind == 0 || evt.Kind == 3 || (evt.Kind >= 10000 && evt.Kind < 20000):
+		return fmt.Sprintf("%d:%s:", evt.Kind, evt.Pubkey)
+	case evt.Kind >= 30000 && evt.Kind < 40000:
+		return fmt.Sprintf("%d:%s:%s", evt.Kind, evt.Pubkey, evt.Tags.FindKeyD())
+	}
+
+	return ""
+}
+
 func escapeSpecialChars(s string) string {
 	s = strings.ReplaceAll(s, "\"", `\"`)
 	s = strings.ReplaceAll(s, "\n", `\n`)
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"slices"
+	"strings"
+)
+
 type Timestamp int64
 
 type TagMap map[string][]string
@@ -15,9 +20,101 @@ type Filter struct {
 	Search  string     `json:"search"`
 }
 
+// Matches check event matches filter
+func (f *Filter) Matches(evt *Event) bool {
+	return f.MatchesWith(evt, nil)
+}
+
+// MatchesWith check event matches filter
+// metadata คืน metadata (kind 0) ของผู้สร้าง event ใช้กับ search extension domain
+func (f *Filter) MatchesWith(evt *Event, metadata func() *Event) bool {
+	if evt == nil {
+		return false
+	}
+
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, evt.ID) {
+		return false
+	}
+
+	if len(f.Kinds) > 0 && !slices.Contains(f.Kinds, evt.Kind) {
+		return false
+	}
+
+	if len(f.Authors) > 0 && !slices.Contains(f.Authors, evt.Pubkey) {
+		return false
+	}
+
+	if f.Since != nil && evt.CreatedAt < *f.Since {
+		return false
+	}
+
+	if f.Until != nil && evt.CreatedAt > *f.Until {
+		return false
+	}
+
+	for k, values := range f.Tags {
+		if len(values) == 0 {
+			continue
+		}
+
+		// key ของ filter อยู่ในรูป "#e"
+		key := strings.TrimPrefix(k, "#")
+		if !evt.Tags.ContainsAny(key, values) {
+			return false
+		}
+	}
+
+	if f.Search != "" && !f.matchSearch(evt, metadata) {
+		return false
+	}
+
+	return true
+}
+
+// matchSearch check event matches search (NIP-50) ด้วยเงื่อนไขเดียวกับการค้นหาใน database
+// include:spam ไม่ต้อง check เพราะ event จาก pubkey ใน blacklist ถูก reject ตั้งแต่รับเข้ามา
+func (f *Filter) matchSearch(evt *Event, metadata func() *Event) bool {
+	search := ParseSearch(f.Search)
+
+	if !search.Match(evt.Content) {
+		return false
+	}
+
+	if language, ok := search.Extensions["language"]; ok {
+		if !evt.Tags.ContainsAny("l", []string{language}) {
+			return false
+		}
+	}
+
+	if _, ok := search.Extensions["domain"]; ok {
+		if metadata == nil || !search.MatchDomain(metadata()) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type Filters []Filter
 
+// Match check event matches any filter
+func (fs Filters) Match(evt *Event) bool {
+	return fs.MatchWith(evt, nil)
+}
+
+// MatchWith check event matches any filter
+// metadata คืน metadata (kind 0) ของผู้สร้าง event ใช้กับ search extension domain
+func (fs Filters) MatchWith(evt *Event, metadata func() *Event) bool {
+	for i := range fs {
+		if fs[i].MatchesWith(evt, metadata) {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Subscription struct {
 	ID      string
-	Filters []Filter
+	Filters Filters
 }
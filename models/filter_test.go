@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMatches(t *testing.T) {
+	since := Timestamp(1740805000)
+	until := Timestamp(1740806000)
+	evt := &Event{
+		ID:        "266f5bc338392bd5404d8f5553ac653d61d0217ad345f711a9b9d60c4d692015",
+		Pubkey:    "f1e6db4c8ffad88a44f763946fec9885d794a49343ae4823c4a000706a3697e7",
+		CreatedAt: 1740805537,
+		Kind:      1,
+		Tags:      Tags{{"e", "eee_1"}, {"p", "ppp_2"}},
+		Content:   "Hello Nostr",
+	}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		match  bool
+	}{
+		{"empty", Filter{}, true},
+		{"kind", Filter{Kinds: []int{1, 7}}, true},
+		{"other kind", Filter{Kinds: []int{7}}, false},
+		{"author", Filter{Authors: []string{evt.Pubkey}}, true},
+		{"other author", Filter{Authors: []string{"abc"}}, false},
+		{"id", Filter{IDs: []string{evt.ID}}, true},
+		{"time range", Filter{Since: &since, Until: &until}, true},
+		{"since after", Filter{Since: &until}, false},
+		{"tag", Filter{Tags: TagMap{"#e": []string{"eee_1"}}}, true},
+		{"tag other key", Filter{Tags: TagMap{"#p": []string{"eee_1"}}}, false},
+		{"search", Filter{Search: "nostr"}, true},
+		{"search miss", Filter{Search: "bitcoin"}, false},
+		{"search words", Filter{Search: "hello nostr include:spam"}, true},
+		{"search language miss", Filter{Search: "nostr language:en"}, false},
+		{"search partial word", Filter{Search: "nost"}, false},
+		{"search phrase", Filter{Search: `"hello nostr"`}, true},
+		{"search phrase order", Filter{Search: `"nostr hello"`}, false},
+		{"search or", Filter{Search: "bitcoin or nostr"}, true},
+		{"search negate", Filter{Search: "hello -nostr"}, false},
+		{"search domain without metadata", Filter{Search: "nostr domain:example.com"}, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, c.filter.Matches(evt), c.name)
+	}
+
+	filters := Filters{{Kinds: []int{7}}, {Authors: []string{evt.Pubkey}}}
+	assert.True(t, filters.Match(evt))
+}
+
+func TestParseSearch(t *testing.T) {
+	search := ParseSearch("best nostr apps include:spam language:EN https://example.com")
+	assert.Equal(t, "best nostr apps https://example.com", search.Terms)
+	assert.Equal(t, "en", search.Extensions["language"])
+	assert.True(t, search.IncludeSpam())
+	assert.Empty(t, search.Unsupported)
+
+	search = ParseSearch("nostr nsfw:false sentiment:positive")
+	assert.Equal(t, "nostr", search.Terms)
+	assert.Equal(t, []string{"nsfw", "sentiment"}, search.Unsupported)
+}
+
+func TestSearchMatchDomain(t *testing.T) {
+	search := ParseSearch("nostr domain:example.com")
+	assert.True(t, search.MatchDomain(&Event{Content: `{"nip05":"alice@Example.com"}`}))
+	assert.False(t, search.MatchDomain(&Event{Content: `{"nip05":"alice@other.com"}`}))
+	assert.False(t, search.MatchDomain(nil))
+
+	filter := Filter{Search: "nostr domain:example.com"}
+	metadata := func() *Event { return &Event{Content: `{"nip05":"_@example.com"}`} }
+	assert.True(t, filter.MatchesWith(&Event{Content: "hello nostr"}, metadata))
+}
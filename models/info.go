@@ -1,15 +1,25 @@
 package models
 
 type RelayInformationDocument struct {
-	Name          string                   `json:"name"`
-	Description   string                   `json:"description"`
-	Pubkey        string                   `json:"pubkey"`
-	Contact       string                   `json:"contact"`
-	SupportedNIPs []int                    `json:"supported_nips"`
-	Software      string                   `json:"software"`
-	Version       string                   `json:"version"`
-	Limitation    *RelayLimitationDocument `json:"limitation,omitempty"`
-	Icon          string                   `json:"icon"`
+	Name           string                    `json:"name"`
+	Description    string                    `json:"description"`
+	Banner         string                    `json:"banner,omitempty"`
+	Icon           string                    `json:"icon"`
+	Pubkey         string                    `json:"pubkey"`
+	Contact        string                    `json:"contact"`
+	SupportedNIPs  []int                     `json:"supported_nips"`
+	Software       string                    `json:"software"`
+	Version        string                    `json:"version"`
+	PrivacyPolicy  string                    `json:"privacy_policy,omitempty"`
+	TermsOfService string                    `json:"terms_of_service,omitempty"`
+	Limitation     *RelayLimitationDocument  `json:"limitation,omitempty"`
+	Retention      []*RelayRetentionDocument `json:"retention,omitempty"`
+	RelayCountries []string                  `json:"relay_countries,omitempty"`
+	LanguageTags   []string                  `json:"language_tags,omitempty"`
+	Tags           []string                  `json:"tags,omitempty"`
+	PostingPolicy  string                    `json:"posting_policy,omitempty"`
+	PaymentsURL    string                    `json:"payments_url,omitempty"`
+	Fees           *RelayFeesDocument        `json:"fees,omitempty"`
 }
 
 type RelayLimitationDocument struct {
@@ -25,3 +35,28 @@ type RelayLimitationDocument struct {
 	PaymentRequired  bool `json:"payment_required"`
 	RestrictedWrites bool `json:"restricted_writes"`
 }
+
+// RelayRetentionDocument kinds เป็นได้ทั้งเลข kind หรือช่วง [from, to]
+type RelayRetentionDocument struct {
+	Kinds []interface{} `json:"kinds,omitempty"`
+	Time  *int64        `json:"time,omitempty"`
+	Count *int64        `json:"count,omitempty"`
+}
+
+type RelayFeesDocument struct {
+	Admission    []*RelayFeeDocument `json:"admission,omitempty"`
+	Subscription []*RelayFeeDocument `json:"subscription,omitempty"`
+	Publication  []*RelayFeeDocument `json:"publication,omitempty"`
+}
+
+type RelayFeeDocument struct {
+	Amount int64  `json:"amount"`
+	Unit   string `json:"unit"`
+	Period int64  `json:"period,omitempty"`
+	Kinds  []int  `json:"kinds,omitempty"`
+}
+
+// IsEmpty check fees is not configured
+func (f *RelayFeesDocument) IsEmpty() bool {
+	return f == nil || (len(f.Admission) == 0 && len(f.Subscription) == 0 && len(f.Publication) == 0)
+}
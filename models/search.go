@@ -0,0 +1,183 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-json"
+)
+
+// extension ของ NIP-50 ที่รองรับการแยกออกจากคำค้นหา
+var searchExtensions = map[string]bool{
+	"include":  true,
+	"language": true,
+	"domain":   true,
+}
+
+// extension ของ NIP-50 ที่ไม่รองรับ filter ที่ใช้จะถูก reject
+var unsupportedSearchExtensions = map[string]bool{
+	"sentiment": true,
+	"nsfw":      true,
+}
+
+// Search คำค้นหา NIP-50 ที่แยก extension (key:value) ออกแล้ว
+type Search struct {
+	Terms       string
+	Extensions  map[string]string
+	Unsupported []string
+}
+
+// ParseSearch parse search string
+func ParseSearch(s string) *Search {
+	search := &Search{Extensions: make(map[string]string)}
+
+	var terms []string
+	for _, token := range strings.Fields(s) {
+		key, value, found := strings.Cut(token, ":")
+		key = strings.ToLower(key)
+		if found && value != "" && searchExtensions[key] {
+			search.Extensions[key] = strings.ToLower(value)
+			continue
+		}
+		if found && value != "" && unsupportedSearchExtensions[key] {
+			search.Unsupported = append(search.Unsupported, key)
+			continue
+		}
+		terms = append(terms, token)
+	}
+	search.Terms = strings.Join(terms, " ")
+
+	return search
+}
+
+// IncludeSpam check extension include:spam
+func (s *Search) IncludeSpam() bool {
+	return s.Extensions["include"] == "spam"
+}
+
+// Match check content ตรงกับคำค้นหา
+// ใช้รูปแบบเดียวกับ websearch_to_tsquery ของ postgres: ทุกคำต้องตรง (AND), "วลี", or และ -คำที่ไม่ต้องการ
+// ตัดคำแบบ text search config simple (ไม่ตัดรากศัพท์)
+func (s *Search) Match(content string) bool {
+	words := searchTokens(content)
+	for _, group := range parseSearchTerms(s.Terms) {
+		matched := false
+		for _, term := range group {
+			if containsPhrase(words, term.words) != term.negate {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchDomain check NIP-05 ใน metadata (kind 0) อยู่ใน domain ของ extension domain
+func (s *Search) MatchDomain(metadata *Event) bool {
+	domain, ok := s.Extensions["domain"]
+	if !ok {
+		return true
+	}
+	if metadata == nil {
+		return false
+	}
+
+	var profile struct {
+		NIP05 string `json:"nip05"`
+	}
+	if json.Unmarshal([]byte(metadata.Content), &profile) != nil {
+		return false
+	}
+
+	_, host, found := strings.Cut(profile.NIP05, "@")
+	return found && strings.EqualFold(host, domain)
+}
+
+type searchTerm struct {
+	words  []string
+	negate bool
+}
+
+// parseSearchTerms แยกคำค้นหาเป็นกลุ่มที่ต้องตรงทุกกลุ่ม (AND) แต่ละกลุ่มตรงคำใดก็ได้ (OR)
+func parseSearchTerms(terms string) [][]searchTerm {
+	var groups [][]searchTerm
+	or := false
+	for len(terms) > 0 {
+		terms = strings.TrimLeftFunc(terms, unicode.IsSpace)
+		if terms == "" {
+			break
+		}
+
+		negate := false
+		if terms[0] == '-' {
+			negate = true
+			terms = terms[1:]
+		}
+
+		var raw string
+		if strings.HasPrefix(terms, `"`) {
+			end := strings.Index(terms[1:], `"`)
+			if end < 0 {
+				raw, terms = terms[1:], ""
+			} else {
+				raw, terms = terms[1:end+1], terms[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(terms, unicode.IsSpace)
+			if end < 0 {
+				raw, terms = terms, ""
+			} else {
+				raw, terms = terms[:end], terms[end:]
+			}
+
+			if !negate && strings.EqualFold(raw, "or") {
+				or = len(groups) > 0
+				continue
+			}
+		}
+
+		words := searchTokens(raw)
+		if len(words) == 0 {
+			continue
+		}
+
+		term := searchTerm{words: words, negate: negate}
+		if or {
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		} else {
+			groups = append(groups, []searchTerm{term})
+		}
+		or = false
+	}
+
+	return groups
+}
+
+// searchTokens ตัดคำเป็นตัวพิมพ์เล็ก แยกด้วยอักขระที่ไม่ใช่ตัวอักษรหรือตัวเลข
+func searchTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// containsPhrase check words มีคำใน phrase เรียงติดกัน
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		matched := true
+		for j := range phrase {
+			if words[i+j] != phrase[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Setting ค่าที่แก้ไขได้ขณะรีเลย์ทำงาน เช่น ชื่อและคำอธิบายรีเลย์ (NIP-86)
+type Setting struct {
+	Key       string    `json:"key" gorm:"type:varchar(64);primaryKey"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Setting) TableName() string {
+	return "settings"
+}
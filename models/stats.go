@@ -0,0 +1,7 @@
+package models
+
+// EventStats สถิติของ event ที่จัดเก็บ
+type EventStats struct {
+	Count         int64      `json:"count"`
+	LastCreatedAt *Timestamp `json:"last_created_at"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/goccy/go-json"
@@ -80,6 +81,31 @@ func (t *Tags) FindAll(prefix string) *Tags {
 	return &result
 }
 
+func (t *Tags) ContainsAny(key string, values []string) bool {
+	for _, v := range *t {
+		if len(v) < 2 || v.Key() != key {
+			continue
+		}
+
+		if slices.Contains(values, v.Value()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsProtected check event มี tag ["-"] (NIP-70)
+func (t *Tags) IsProtected() bool {
+	for _, v := range *t {
+		if len(v) > 0 && v.Key() == "-" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (t *Tags) Serialize() string {
 	var strTags []string
 	for _, tag := range *t {
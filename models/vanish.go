@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Vanish คำขอลบข้อมูลทั้งหมดของ pubkey (NIP-62)
+// event ของ pubkey ที่ created_at ไม่เกิน Until จะไม่ถูกรับเข้ามาอีก
+type Vanish struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	Pubkey    string    `json:"pubkey" gorm:"type:varchar(64);uniqueIndex"`
+	EventID   string    `json:"event_id" gorm:"type:varchar(64)"`
+	Until     Timestamp `json:"until" gorm:"type:integer"`
+}
+
+func (Vanish) TableName() string {
+	return "vanishes"
+}
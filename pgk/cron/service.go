@@ -1,18 +1,29 @@
 package cron
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/robfig/cron/v3"
 
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/metrics"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip13"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+	"github.com/saveblush/reraw-relay/pgk/policies"
+	"github.com/saveblush/reraw-relay/pgk/wot"
 )
 
 // Service service interface
 type Service interface {
 	Start()
-	Stop()
+	Stop() context.Context
+	Running() bool
 }
 
 type service struct {
@@ -20,6 +31,14 @@ type service struct {
 	config     *config.Configs
 	cron       *cron.Cron
 	eventstore eventstore.Service
+	nip13      nip13.Service
+	nip45      nip45.Service
+	policies   policies.Service
+	wot        wot.Service
+	running    atomic.Bool
+
+	// job ที่รันทันทีนอกรอบของ cron
+	background sync.WaitGroup
 }
 
 func NewService() Service {
@@ -28,6 +47,10 @@ func NewService() Service {
 		config:     config.CF,
 		cron:       cron.New(),
 		eventstore: eventstore.NewService(),
+		nip13:      nip13.NewService(),
+		nip45:      nip45.NewService(),
+		policies:   policies.NewService(),
+		wot:        wot.NewService(),
 	}
 }
 
@@ -35,20 +58,101 @@ func (s *service) Start() {
 	logger.Log.Info("Cron init...")
 	s.schedule()
 	s.cron.Start()
+	s.running.Store(true)
+}
+
+// Stop หยุดการตั้งเวลา
+// context จะ done เมื่อ job ที่กำลังรันอยู่ (รวม job ที่รันทันทีตอนเริ่ม) ทำงานเสร็จ
+func (s *service) Stop() context.Context {
+	s.running.Store(false)
+	stopped := s.cron.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopped.Done()
+		s.background.Wait()
+		cancel()
+	}()
+
+	return ctx
 }
 
-func (s *service) Stop() {
-	s.cron.Stop()
+// Running check cron กำลังทำงาน
+func (s *service) Running() bool {
+	return s.running.Load()
 }
 
 func (s *service) schedule() {
 	// รันทุก 5 นาที
-	s.cron.AddFunc("*/5 * * * *", func() {
-		s.eventstore.ClearEventsExpiration(s.cctx)
-	})
+	s.cron.AddFunc("*/5 * * * *", s.job("clear_events_expiration", func() {
+		deleted, err := s.eventstore.ClearEventsExpiration(s.cctx)
+		if err != nil {
+			return
+		}
+
+		err = s.nip45.InvalidateEvents(s.cctx, deleted)
+		if err != nil {
+			logger.Log.Errorf("invalidate hll error: %s", err)
+		}
+	}))
 
 	// รันทุก 30 นาที
-	s.cron.AddFunc("*/30 * * * *", func() {
-		s.eventstore.ClearEventsWithBlacklist(s.cctx)
-	})
+	s.cron.AddFunc("*/30 * * * *", s.job("clear_events_with_blacklist", func() {
+		// event ที่ลบไปแล้วก่อนเกิด error ต้องลบ register ด้วย
+		deleted, _ := s.eventstore.ClearEventsWithBlacklist(s.cctx)
+		err := s.nip45.InvalidateEvents(s.cctx, deleted)
+		if err != nil {
+			logger.Log.Errorf("invalidate hll error: %s", err)
+		}
+	}))
+
+	// รันทุกนาที รายการแบนและ allow kinds ที่เปลี่ยนจากรีเลย์อื่นที่ใช้ฐานข้อมูลเดียวกัน
+	s.cron.AddFunc("* * * * *", s.job("refresh_policy_lists", func() {
+		s.policies.RefreshLists(s.cctx)
+	}))
+
+	// รันทุกวัน
+	s.cron.AddFunc("0 0 * * *", s.job("clear_block_ips_expired", func() {
+		s.eventstore.ClearBlockIPsExpired(s.cctx)
+	}))
+
+	// allowlist ของ pow โหลดทันทีครั้งแรก แล้วรันทุกนาที
+	if len(s.config.App.Pow.Rules) > 0 || s.config.App.Pow.Adaptive.Enable {
+		refresh := s.job("refresh_pow_cache", func() {
+			s.nip13.Refresh(s.cctx)
+		})
+
+		s.cron.AddFunc("* * * * *", refresh)
+		s.runNow(refresh)
+	}
+
+	// web of trust คำนวณทันทีครั้งแรก แล้วรันตามรอบที่กำหนด
+	if s.config.WebOfTrust.Enable {
+		refresh := s.job("refresh_web_of_trust", func() {
+			s.wot.Refresh(s.cctx)
+		})
+
+		_, err := s.cron.AddFunc(s.config.WebOfTrust.Schedule, refresh)
+		if err != nil {
+			logger.Log.Errorf("schedule web of trust error: %s", err)
+		}
+		s.runNow(refresh)
+	}
+}
+
+// runNow รัน job ทันทีเบื้องหลัง โดย Stop จะรอให้ทำงานเสร็จ
+func (s *service) runNow(fn func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+}
+
+// job ห่อ job เพื่อบันทึกเวลาที่ใช้รัน
+func (s *service) job(name string, fn func()) func() {
+	return func() {
+		defer metrics.ObserveCron(name, time.Now())
+		fn()
+	}
 }
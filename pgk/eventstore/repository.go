@@ -2,14 +2,19 @@ package eventstore
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/goccy/go-json"
 
 	"github.com/saveblush/reraw-relay/core/config"
 	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/metrics"
+	"github.com/saveblush/reraw-relay/core/sql"
 	"github.com/saveblush/reraw-relay/core/utils"
 	"github.com/saveblush/reraw-relay/models"
 )
@@ -20,12 +25,39 @@ type Repository interface {
 	FindAll(db *gorm.DB, req *Request) ([]*models.Event, error)
 	FindByID(db *gorm.DB, ID string) (*models.Event, error)
 	Count(db *gorm.DB, req *Request) (*int64, error)
+	CountUnion(db *gorm.DB, reqs []*Request) (*int64, error)
+	FindPubkeys(db *gorm.DB, req *Request) ([]string, error)
+	Stats(db *gorm.DB) (*models.EventStats, error)
 	Insert(db *gorm.DB, req *models.Event) error
 	SoftDelete(db *gorm.DB, req *models.Event) error
+	SoftDeleteByPubkey(db *gorm.DB, pubkey string) ([]*models.Event, error)
 	Delete(db *gorm.DB, req *models.Event) error
 	InsertBlacklist(db *gorm.DB, req *models.Blacklist) error
 	FindBlacklists(db *gorm.DB, req *models.Blacklist) ([]*models.Blacklist, error)
-	FindEventsExpiration(db *gorm.DB) ([]*models.Event, error)
+	DeleteBlacklist(db *gorm.DB, req *models.Blacklist) error
+	InsertAllowlist(db *gorm.DB, req *models.Allowlist) error
+	FindAllowlists(db *gorm.DB) ([]*models.Allowlist, error)
+	CountAllowlists(db *gorm.DB, pubkeys []string) (int64, error)
+	DeleteAllowlist(db *gorm.DB, pubkey string) error
+	InsertAllowKind(db *gorm.DB, req *models.AllowKind) error
+	FindAllowKinds(db *gorm.DB) ([]*models.AllowKind, error)
+	DeleteAllowKind(db *gorm.DB, kind int) error
+	DeleteEventsExpired(db *gorm.DB, before int64) ([]*models.Event, error)
+	InsertBlockIP(db *gorm.DB, req *models.BlockIP) error
+	FindBlockIP(db *gorm.DB, ip string) (*models.BlockIP, error)
+	FindBlockIPs(db *gorm.DB) ([]*models.BlockIP, error)
+	DeleteBlockIP(db *gorm.DB, ip string) error
+	DeleteBlockIPsExpired(db *gorm.DB, before time.Time) error
+	FindHLLs(db *gorm.DB, keys []string) ([]*models.HLLCount, error)
+	MergeHLLs(db *gorm.DB, req []*models.HLLCount) error
+	DeleteHLLs(db *gorm.DB, keys []string) error
+	InsertDeletions(db *gorm.DB, req []*models.Deletion) error
+	IsDeleted(db *gorm.DB, req *models.Event) (bool, error)
+	InsertSetting(db *gorm.DB, req *models.Setting) error
+	FindSettings(db *gorm.DB) ([]*models.Setting, error)
+	InsertVanish(db *gorm.DB, req *models.Vanish) error
+	FindVanish(db *gorm.DB, pubkey string) (*models.Vanish, error)
+	DeleteEventsVanish(db *gorm.DB, req *models.Vanish) error
 }
 
 type repository struct {
@@ -44,10 +76,12 @@ func (r *repository) query(req *Request) (string, []any, error) {
 	var conditions []string
 	var params []any
 
-	//conditions = append(conditions, `(deleted_at IS NULL AND (CASE WHEN `+strconv.Itoa(int(utils.Now().Unix()))+` > expiration THEN 1 ELSE 0 END) = ?)`)
-	//params = append(params, 0)
 	conditions = append(conditions, `(deleted_at IS NULL)`)
 
+	// ไม่แสดง event ที่หมดอายุแล้ว (NIP-40)
+	conditions = append(conditions, `(expiration IS NULL OR expiration = 0 OR expiration > ?)`)
+	params = append(params, utils.Now().Unix())
+
 	if len(req.NostrFilter.IDs) > 0 {
 		for _, v := range req.NostrFilter.IDs {
 			params = append(params, v)
@@ -69,9 +103,12 @@ func (r *repository) query(req *Request) (string, []any, error) {
 		conditions = append(conditions, `pubkey IN (`+makePlaceParams(len(req.NostrFilter.Authors))+`)`)
 	}
 
+	var search *models.Search
 	if req.NostrFilter.Search != "" {
-		conditions = append(conditions, `content LIKE ?`)
-		params = append(params, `%`+strings.ReplaceAll(req.NostrFilter.Search, `%`, `\%`)+`%`)
+		search = models.ParseSearch(req.NostrFilter.Search)
+		searchConditions, searchParams := r.searchConditions(search)
+		conditions = append(conditions, searchConditions...)
+		params = append(params, searchParams...)
 	}
 
 	if !generic.IsEmpty(req.NostrFilter.Since) {
@@ -105,6 +142,11 @@ func (r *repository) query(req *Request) (string, []any, error) {
 	var sqlLimit string
 	if req.NostrFilter.Limit > 0 {
 		limit = req.NostrFilter.Limit
+
+		// จำกัด limit ไม่ให้เกิน max_limit ที่ประกาศไว้ใน NIP-11
+		if config.CF.Info.Limitation.MaxLimit > 0 && limit > config.CF.Info.Limitation.MaxLimit {
+			limit = config.CF.Info.Limitation.MaxLimit
+		}
 	} else if config.CF.Info.Limitation.MaxLimit > 0 {
 		if !req.DoCount {
 			limit = config.CF.Info.Limitation.MaxLimit
@@ -118,20 +160,32 @@ func (r *repository) query(req *Request) (string, []any, error) {
 		}
 	}
 
-	// กรณี noLimit = true จะมาฟังชั่นอื่น เพราะไม่ต้องการ limit เช่น การลบเหตุการณ์(NIP-09)
-	if !req.NoLimit {
-		sqlLimit = "LIMIT ?"
-		params = append(params, limit)
-	}
-
 	var sqlField string
 	var sqlOrderBy string
 	if req.DoCount {
 		sqlField = "COUNT(1)"
 		sqlOrderBy = ""
+	} else if req.DoPubkeys {
+		sqlField = "DISTINCT pubkey"
+		sqlOrderBy = ""
 	} else {
 		sqlField = "id, created_at, pubkey, kind, content, tags, sig"
+		if req.DoIDs {
+			sqlField = "id"
+		}
 		sqlOrderBy = "ORDER BY created_at DESC, id"
+
+		// NIP-50 เรียงตามความเกี่ยวข้องของคำค้นหา
+		if search != nil && search.Terms != "" {
+			sqlOrderBy = "ORDER BY ts_rank(search_vector, websearch_to_tsquery(?::regconfig, ?)) DESC, created_at DESC, id"
+			params = append(params, sql.SearchLanguage(), search.Terms)
+		}
+	}
+
+	// กรณี noLimit = true จะมาฟังชั่นอื่น เพราะไม่ต้องการ limit เช่น การลบเหตุการณ์(NIP-09)
+	if !req.NoLimit {
+		sqlLimit = "LIMIT ?"
+		params = append(params, limit)
 	}
 
 	sql := `SELECT ` + sqlField + `
@@ -143,6 +197,8 @@ func (r *repository) query(req *Request) (string, []any, error) {
 }
 
 func (r *repository) Find(db *gorm.DB, req *Request) (*models.Event, error) {
+	defer metrics.ObserveQuery("Find", time.Now())
+
 	sql, params, err := r.query(req)
 	if err != nil {
 		return nil, err
@@ -158,6 +214,8 @@ func (r *repository) Find(db *gorm.DB, req *Request) (*models.Event, error) {
 }
 
 func (r *repository) FindAll(db *gorm.DB, req *Request) ([]*models.Event, error) {
+	defer metrics.ObserveQuery("FindAll", time.Now())
+
 	sql, params, err := r.query(req)
 	if err != nil {
 		return nil, err
@@ -173,6 +231,8 @@ func (r *repository) FindAll(db *gorm.DB, req *Request) ([]*models.Event, error)
 }
 
 func (r *repository) FindByID(db *gorm.DB, ID string) (*models.Event, error) {
+	defer metrics.ObserveQuery("FindByID", time.Now())
+
 	entities := &models.Event{}
 	err := db.WithContext(r.ctx).Limit(1).Where("id = ?", ID).Find(entities).Error
 	if err != nil {
@@ -183,6 +243,8 @@ func (r *repository) FindByID(db *gorm.DB, ID string) (*models.Event, error) {
 }
 
 func (r *repository) Count(db *gorm.DB, req *Request) (*int64, error) {
+	defer metrics.ObserveQuery("Count", time.Now())
+
 	sql, params, err := r.query(req)
 	if err != nil {
 		return nil, err
@@ -197,7 +259,67 @@ func (r *repository) Count(db *gorm.DB, req *Request) (*int64, error) {
 	return entities, nil
 }
 
+func (r *repository) CountUnion(db *gorm.DB, reqs []*Request) (*int64, error) {
+	defer metrics.ObserveQuery("CountUnion", time.Now())
+
+	var sqls []string
+	var params []any
+	for _, req := range reqs {
+		sql, p, err := r.query(req)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, `(`+sql+`)`)
+		params = append(params, p...)
+	}
+
+	// นับ id ที่ไม่ซ้ำกันจากทุก filter
+	sql := `SELECT COUNT(1) FROM (` + strings.Join(sqls, " UNION ") + `) AS t`
+
+	var entities *int64
+	err := db.WithContext(r.ctx).Raw(sql, params...).Scan(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) FindPubkeys(db *gorm.DB, req *Request) ([]string, error) {
+	defer metrics.ObserveQuery("FindPubkeys", time.Now())
+
+	sql, params, err := r.query(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []string{}
+	err = db.WithContext(r.ctx).Raw(sql, params...).Scan(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) Stats(db *gorm.DB) (*models.EventStats, error) {
+	defer metrics.ObserveQuery("Stats", time.Now())
+
+	entities := &models.EventStats{}
+	sql := `SELECT COUNT(1) AS count, MAX(created_at) AS last_created_at
+			FROM ` + models.Event{}.TableName() + `
+			WHERE deleted_at IS NULL`
+	err := db.WithContext(r.ctx).Raw(sql).Scan(entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
 func (r *repository) Insert(db *gorm.DB, req *models.Event) error {
+	defer metrics.ObserveQuery("Insert", time.Now())
+
 	tags, errTags := json.Marshal(&req.Tags)
 	if errTags != nil {
 		return errTags
@@ -224,6 +346,8 @@ func (r *repository) Insert(db *gorm.DB, req *models.Event) error {
 }
 
 func (r *repository) SoftDelete(db *gorm.DB, req *models.Event) error {
+	defer metrics.ObserveQuery("SoftDelete", time.Now())
+
 	err := db.Model(&req).Select("DeletedAt").Updates(&models.Event{DeletedAt: utils.Pointer(models.Timestamp(utils.Now().Unix()))}).Error
 	if err != nil {
 		return err
@@ -232,7 +356,25 @@ func (r *repository) SoftDelete(db *gorm.DB, req *models.Event) error {
 	return nil
 }
 
+func (r *repository) SoftDeleteByPubkey(db *gorm.DB, pubkey string) ([]*models.Event, error) {
+	defer metrics.ObserveQuery("SoftDeleteByPubkey", time.Now())
+
+	// คืน kind และ tags ของ event ที่ถูกลบ
+	entities := []*models.Event{}
+	err := db.WithContext(r.ctx).Model(&entities).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "kind"}, {Name: "tags"}}}).
+		Where("pubkey = ? AND deleted_at IS NULL", pubkey).
+		Update("deleted_at", utils.Now().Unix()).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
 func (r *repository) Delete(db *gorm.DB, req *models.Event) error {
+	defer metrics.ObserveQuery("Delete", time.Now())
+
 	err := db.Delete(&req).Error
 	if err != nil {
 		return err
@@ -242,12 +384,22 @@ func (r *repository) Delete(db *gorm.DB, req *models.Event) error {
 }
 
 func (r *repository) InsertBlacklist(db *gorm.DB, req *models.Blacklist) error {
-	query := db.Model(&models.Blacklist{})
-	query.Where("pubkey = ?", req.Pubkey)
-	query.Updates(&req)
-	row := query.RowsAffected
-	if row == 0 {
-		err := db.Model(&models.Blacklist{}).Create(&req).Error
+	defer metrics.ObserveQuery("InsertBlacklist", time.Now())
+
+	if req.Pubkey == "" && req.EventID == "" && req.Kind == nil {
+		return errors.New("blacklist pubkey, event id or kind is required")
+	}
+
+	query := r.queryFindBots(db.WithContext(r.ctx).Model(&models.Blacklist{}), req).Updates(map[string]interface{}{
+		"reason":     req.Reason,
+		"updated_at": time.Now(),
+	})
+	if query.Error != nil {
+		return query.Error
+	}
+
+	if query.RowsAffected == 0 {
+		err := db.WithContext(r.ctx).Model(&models.Blacklist{}).Create(&req).Error
 		if err != nil {
 			return err
 		}
@@ -261,10 +413,20 @@ func (r *repository) queryFindBots(db *gorm.DB, req *models.Blacklist) *gorm.DB
 		db = db.Where("pubkey = ?", req.Pubkey)
 	}
 
+	if !generic.IsEmpty(req.EventID) {
+		db = db.Where("event_id = ?", req.EventID)
+	}
+
+	if req.Kind != nil {
+		db = db.Where("kind = ?", *req.Kind)
+	}
+
 	return db
 }
 
 func (r *repository) FindBlacklists(db *gorm.DB, req *models.Blacklist) ([]*models.Blacklist, error) {
+	defer metrics.ObserveQuery("FindBlacklists", time.Now())
+
 	entities := []*models.Blacklist{}
 	query := r.queryFindBots(db, req)
 	err := query.WithContext(r.ctx).Find(&entities).Error
@@ -275,14 +437,354 @@ func (r *repository) FindBlacklists(db *gorm.DB, req *models.Blacklist) ([]*mode
 	return entities, nil
 }
 
-func (r *repository) FindEventsExpiration(db *gorm.DB) ([]*models.Event, error) {
+func (r *repository) DeleteBlacklist(db *gorm.DB, req *models.Blacklist) error {
+	defer metrics.ObserveQuery("DeleteBlacklist", time.Now())
+
+	if req.Pubkey == "" && req.EventID == "" && req.Kind == nil {
+		return errors.New("blacklist pubkey, event id or kind is required")
+	}
+
+	err := r.queryFindBots(db.WithContext(r.ctx), req).Delete(&models.Blacklist{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) InsertAllowlist(db *gorm.DB, req *models.Allowlist) error {
+	defer metrics.ObserveQuery("InsertAllowlist", time.Now())
+
+	err := db.WithContext(r.ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "pubkey"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "updated_at"}),
+	}).Create(&req).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindAllowlists(db *gorm.DB) ([]*models.Allowlist, error) {
+	defer metrics.ObserveQuery("FindAllowlists", time.Now())
+
+	entities := []*models.Allowlist{}
+	err := db.WithContext(r.ctx).Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) CountAllowlists(db *gorm.DB, pubkeys []string) (int64, error) {
+	defer metrics.ObserveQuery("CountAllowlists", time.Now())
+
+	var count int64
+	err := db.WithContext(r.ctx).Model(&models.Allowlist{}).Where("pubkey IN ?", pubkeys).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *repository) DeleteAllowlist(db *gorm.DB, pubkey string) error {
+	defer metrics.ObserveQuery("DeleteAllowlist", time.Now())
+
+	err := db.WithContext(r.ctx).Unscoped().Where("pubkey = ?", pubkey).Delete(&models.Allowlist{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) InsertAllowKind(db *gorm.DB, req *models.AllowKind) error {
+	defer metrics.ObserveQuery("InsertAllowKind", time.Now())
+
+	err := db.WithContext(r.ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "updated_at"}),
+	}).Create(&req).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindAllowKinds(db *gorm.DB) ([]*models.AllowKind, error) {
+	defer metrics.ObserveQuery("FindAllowKinds", time.Now())
+
+	entities := []*models.AllowKind{}
+	err := db.WithContext(r.ctx).Order("kind").Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) DeleteAllowKind(db *gorm.DB, kind int) error {
+	defer metrics.ObserveQuery("DeleteAllowKind", time.Now())
+
+	err := db.WithContext(r.ctx).Unscoped().Where("kind = ?", kind).Delete(&models.AllowKind{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) InsertSetting(db *gorm.DB, req *models.Setting) error {
+	defer metrics.ObserveQuery("InsertSetting", time.Now())
+
+	err := db.WithContext(r.ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&req).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindSettings(db *gorm.DB) ([]*models.Setting, error) {
+	defer metrics.ObserveQuery("FindSettings", time.Now())
+
+	entities := []*models.Setting{}
+	err := db.WithContext(r.ctx).Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) DeleteEventsExpired(db *gorm.DB, before int64) ([]*models.Event, error) {
+	defer metrics.ObserveQuery("DeleteEventsExpired", time.Now())
+
+	// คืน kind และ tags ของ event ที่ถูกลบ
 	entities := []*models.Event{}
-	query := db.Where("expiration < ?", utils.Now().Unix())
-	query.Where("deleted_at IS NULL")
-	err := query.WithContext(r.ctx).Find(&entities).Error
+	err := db.WithContext(r.ctx).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "kind"}, {Name: "tags"}}}).
+		Where("expiration > 0 AND expiration <= ?", before).
+		Delete(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) InsertBlockIP(db *gorm.DB, req *models.BlockIP) error {
+	defer metrics.ObserveQuery("InsertBlockIP", time.Now())
+
+	query := db.WithContext(r.ctx).Model(&models.BlockIP{}).Where("ip = ?", req.IP).Updates(map[string]interface{}{
+		"reason":     req.Reason,
+		"count":      req.Count,
+		"expires_at": req.ExpiresAt,
+	})
+	if query.Error != nil {
+		return query.Error
+	}
+
+	if query.RowsAffected == 0 {
+		err := db.WithContext(r.ctx).Model(&models.BlockIP{}).Create(&req).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) FindBlockIP(db *gorm.DB, ip string) (*models.BlockIP, error) {
+	defer metrics.ObserveQuery("FindBlockIP", time.Now())
+
+	entities := &models.BlockIP{}
+	err := db.WithContext(r.ctx).Limit(1).Where("ip = ?", ip).Find(entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) FindBlockIPs(db *gorm.DB) ([]*models.BlockIP, error) {
+	defer metrics.ObserveQuery("FindBlockIPs", time.Now())
+
+	entities := []*models.BlockIP{}
+	err := db.WithContext(r.ctx).Where("expires_at > ?", utils.Now()).Order("expires_at DESC").Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) DeleteBlockIP(db *gorm.DB, ip string) error {
+	defer metrics.ObserveQuery("DeleteBlockIP", time.Now())
+
+	err := db.WithContext(r.ctx).Unscoped().Where("ip = ?", ip).Delete(&models.BlockIP{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteBlockIPsExpired(db *gorm.DB, before time.Time) error {
+	defer metrics.ObserveQuery("DeleteBlockIPsExpired", time.Now())
+
+	err := db.WithContext(r.ctx).Unscoped().Where("expires_at < ?", before).Delete(&models.BlockIP{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindHLLs(db *gorm.DB, keys []string) ([]*models.HLLCount, error) {
+	defer metrics.ObserveQuery("FindHLLs", time.Now())
+
+	entities := []*models.HLLCount{}
+	err := db.WithContext(r.ctx).Where("key IN ?", keys).Find(&entities).Error
 	if err != nil {
 		return nil, err
 	}
 
 	return entities, nil
 }
+
+func (r *repository) MergeHLLs(db *gorm.DB, req []*models.HLLCount) error {
+	defer metrics.ObserveQuery("MergeHLLs", time.Now())
+
+	if len(req) == 0 {
+		return nil
+	}
+
+	var values []string
+	var params []any
+	for _, v := range req {
+		values = append(values, `(?, ?, ?, NOW())`)
+		params = append(params, v.Key, v.Registers, v.Backfilled)
+	}
+
+	// รวม register กับของเดิมด้วย hll_merge
+	sql := `INSERT INTO ` + models.HLLCount{}.TableName() + ` (key, registers, backfilled, updated_at)
+			VALUES ` + strings.Join(values, ",") + `
+			ON CONFLICT (key) DO UPDATE SET
+				registers = hll_merge(` + models.HLLCount{}.TableName() + `.registers, EXCLUDED.registers),
+				backfilled = ` + models.HLLCount{}.TableName() + `.backfilled OR EXCLUDED.backfilled,
+				updated_at = EXCLUDED.updated_at`
+	err := db.WithContext(r.ctx).Exec(sql, params...).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteHLLs(db *gorm.DB, keys []string) error {
+	defer metrics.ObserveQuery("DeleteHLLs", time.Now())
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := db.WithContext(r.ctx).Where("key IN ?", keys).Delete(&models.HLLCount{}).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) InsertDeletions(db *gorm.DB, req []*models.Deletion) error {
+	defer metrics.ObserveQuery("InsertDeletions", time.Now())
+
+	if len(req) == 0 {
+		return nil
+	}
+
+	err := db.WithContext(r.ctx).Create(&req).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) IsDeleted(db *gorm.DB, req *models.Event) (bool, error) {
+	defer metrics.ObserveQuery("IsDeleted", time.Now())
+
+	query := db.WithContext(r.ctx).Model(&models.Deletion{}).Where("pubkey = ?", req.Pubkey)
+	if address := req.Address(); address != "" {
+		query = query.Where("(event_id = ? OR (address = ? AND until >= ?))", req.ID, address, req.CreatedAt)
+	} else {
+		query = query.Where("event_id = ?", req.ID)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *repository) InsertVanish(db *gorm.DB, req *models.Vanish) error {
+	defer metrics.ObserveQuery("InsertVanish", time.Now())
+
+	// เก็บคำขอล่าสุดของ pubkey
+	sql := `INSERT INTO ` + models.Vanish{}.TableName() + ` (created_at, updated_at, pubkey, event_id, until)
+			VALUES (NOW(), NOW(), ?, ?, ?)
+			ON CONFLICT (pubkey) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				event_id = EXCLUDED.event_id,
+				until = EXCLUDED.until
+			WHERE ` + models.Vanish{}.TableName() + `.until < EXCLUDED.until`
+	err := db.WithContext(r.ctx).Exec(sql, req.Pubkey, req.EventID, req.Until).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindVanish(db *gorm.DB, pubkey string) (*models.Vanish, error) {
+	defer metrics.ObserveQuery("FindVanish", time.Now())
+
+	entities := &models.Vanish{}
+	err := db.WithContext(r.ctx).Limit(1).Where("pubkey = ?", pubkey).Find(entities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+func (r *repository) DeleteEventsVanish(db *gorm.DB, req *models.Vanish) error {
+	defer metrics.ObserveQuery("DeleteEventsVanish", time.Now())
+
+	tags, err := json.Marshal(models.Tags{{"p", req.Pubkey}})
+	if err != nil {
+		return err
+	}
+
+	// event ทั้งหมดของ pubkey (ยกเว้นคำขอเอง) และ gift wrap (kind 1059) ที่ส่งถึง pubkey
+	sql := `DELETE FROM ` + models.Event{}.TableName() + `
+			WHERE (pubkey = ? AND created_at <= ? AND id <> ?)
+				OR (kind = 1059 AND tags @> ?::jsonb)`
+	err = db.WithContext(r.ctx).Exec(sql, req.Pubkey, req.Until, req.EventID, string(tags)).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -5,5 +5,7 @@ import "github.com/saveblush/reraw-relay/models"
 type Request struct {
 	NostrFilter *models.Filter
 	DoCount     bool
+	DoIDs       bool
+	DoPubkeys   bool
 	NoLimit     bool
 }
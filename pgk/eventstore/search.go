@@ -0,0 +1,41 @@
+package eventstore
+
+import (
+	"regexp"
+
+	"github.com/goccy/go-json"
+
+	"github.com/saveblush/reraw-relay/core/sql"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// searchConditions เงื่อนไขการค้นหา NIP-50
+func (r *repository) searchConditions(search *models.Search) ([]string, []any) {
+	var conditions []string
+	var params []any
+
+	if search.Terms != "" {
+		conditions = append(conditions, `search_vector @@ websearch_to_tsquery(?::regconfig, ?)`)
+		params = append(params, sql.SearchLanguage(), search.Terms)
+	}
+
+	// ไม่แสดง event จาก pubkey ที่อยู่ใน blacklist ยกเว้น include:spam
+	if !search.IncludeSpam() {
+		conditions = append(conditions, `pubkey NOT IN (SELECT pubkey FROM `+models.Blacklist{}.TableName()+` WHERE deleted_at IS NULL)`)
+	}
+
+	// ภาษาจาก label (NIP-32) เช่น ["l", "en", "ISO-639-1"]
+	if language, ok := search.Extensions["language"]; ok {
+		tags, _ := json.Marshal(models.Tags{{"l", language}})
+		conditions = append(conditions, `tags @> ?::jsonb`)
+		params = append(params, string(tags))
+	}
+
+	// domain ของ NIP-05 จาก metadata (kind 0)
+	if domain, ok := search.Extensions["domain"]; ok {
+		conditions = append(conditions, `pubkey IN (SELECT pubkey FROM `+models.Event{}.TableName()+` WHERE kind = 0 AND deleted_at IS NULL AND content ~* ?)`)
+		params = append(params, `"nip05"\s*:\s*"[^"]*@`+regexp.QuoteMeta(domain)+`"`)
+	}
+
+	return conditions, params
+}
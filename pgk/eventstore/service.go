@@ -4,6 +4,7 @@ import (
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
 	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/utils"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
 )
@@ -13,13 +14,40 @@ type Service interface {
 	FindAll(c *cctx.Context, req *Request) ([]*models.Event, error)
 	FindByID(c *cctx.Context, ID string) (*models.Event, error)
 	Count(c *cctx.Context, req *Request) (*int64, error)
+	CountUnion(c *cctx.Context, reqs []*Request) (*int64, error)
+	FindPubkeys(c *cctx.Context, req *Request) ([]string, error)
+	Stats(c *cctx.Context) (*models.EventStats, error)
 	Insert(c *cctx.Context, req *models.Event) error
 	SoftDelete(c *cctx.Context, req *models.Event) error
 	Delete(c *cctx.Context, req *models.Event) error
 	InsertBlacklist(c *cctx.Context, req *models.Blacklist) error
 	FindBlacklists(c *cctx.Context, req *models.Blacklist) ([]*models.Blacklist, error)
-	ClearEventsWithBlacklist(c *cctx.Context) error
-	ClearEventsExpiration(c *cctx.Context) error
+	DeleteBlacklist(c *cctx.Context, req *models.Blacklist) error
+	InsertAllowlist(c *cctx.Context, req *models.Allowlist) error
+	FindAllowlists(c *cctx.Context) ([]*models.Allowlist, error)
+	IsAllowlisted(c *cctx.Context, pubkeys []string) (bool, error)
+	DeleteAllowlist(c *cctx.Context, pubkey string) error
+	InsertAllowKind(c *cctx.Context, req *models.AllowKind) error
+	FindAllowKinds(c *cctx.Context) ([]*models.AllowKind, error)
+	DeleteAllowKind(c *cctx.Context, kind int) error
+	InsertSetting(c *cctx.Context, req *models.Setting) error
+	FindSettings(c *cctx.Context) ([]*models.Setting, error)
+	ClearEventsWithBlacklist(c *cctx.Context) ([]*models.Event, error)
+	ClearEventsWithPubkey(c *cctx.Context, pubkey string) ([]*models.Event, error)
+	ClearEventsExpiration(c *cctx.Context) ([]*models.Event, error)
+	InsertBlockIP(c *cctx.Context, req *models.BlockIP) error
+	FindBlockIP(c *cctx.Context, ip string) (*models.BlockIP, error)
+	FindBlockIPs(c *cctx.Context) ([]*models.BlockIP, error)
+	DeleteBlockIP(c *cctx.Context, ip string) error
+	ClearBlockIPsExpired(c *cctx.Context) error
+	FindHLLs(c *cctx.Context, keys []string) ([]*models.HLLCount, error)
+	MergeHLLs(c *cctx.Context, req []*models.HLLCount) error
+	DeleteHLLs(c *cctx.Context, keys []string) error
+	InsertDeletions(c *cctx.Context, req []*models.Deletion) error
+	IsDeleted(c *cctx.Context, req *models.Event) (bool, error)
+	InsertVanish(c *cctx.Context, req *models.Vanish) error
+	FindVanish(c *cctx.Context, pubkey string) (*models.Vanish, error)
+	DeleteEventsVanish(c *cctx.Context, req *models.Vanish) error
 }
 
 type service struct {
@@ -61,6 +89,33 @@ func (s *service) Count(c *cctx.Context, req *Request) (*int64, error) {
 	return res, nil
 }
 
+func (s *service) CountUnion(c *cctx.Context, reqs []*Request) (*int64, error) {
+	res, err := s.repository.CountUnion(c.GetDatabase(), reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) FindPubkeys(c *cctx.Context, req *Request) ([]string, error) {
+	res, err := s.repository.FindPubkeys(c.GetDatabase(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) Stats(c *cctx.Context) (*models.EventStats, error) {
+	res, err := s.repository.Stats(c.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (s *service) Insert(c *cctx.Context, req *models.Event) error {
 	err := s.repository.Insert(c.GetDatabase(), req)
 	if err != nil {
@@ -106,6 +161,101 @@ func (s *service) FindBlacklists(c *cctx.Context, req *models.Blacklist) ([]*mod
 	return res, nil
 }
 
+func (s *service) DeleteBlacklist(c *cctx.Context, req *models.Blacklist) error {
+	err := s.repository.DeleteBlacklist(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) InsertAllowlist(c *cctx.Context, req *models.Allowlist) error {
+	err := s.repository.InsertAllowlist(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindAllowlists(c *cctx.Context) ([]*models.Allowlist, error) {
+	res, err := s.repository.FindAllowlists(c.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// IsAllowlisted check มี pubkey อย่างน้อยหนึ่งรายการอยู่ใน allowlist
+func (s *service) IsAllowlisted(c *cctx.Context, pubkeys []string) (bool, error) {
+	if len(pubkeys) == 0 {
+		return false, nil
+	}
+
+	count, err := s.repository.CountAllowlists(c.GetDatabase(), pubkeys)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (s *service) DeleteAllowlist(c *cctx.Context, pubkey string) error {
+	err := s.repository.DeleteAllowlist(c.GetDatabase(), pubkey)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) InsertAllowKind(c *cctx.Context, req *models.AllowKind) error {
+	err := s.repository.InsertAllowKind(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindAllowKinds(c *cctx.Context) ([]*models.AllowKind, error) {
+	res, err := s.repository.FindAllowKinds(c.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) DeleteAllowKind(c *cctx.Context, kind int) error {
+	err := s.repository.DeleteAllowKind(c.GetDatabase(), kind)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) InsertSetting(c *cctx.Context, req *models.Setting) error {
+	err := s.repository.InsertSetting(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindSettings(c *cctx.Context) ([]*models.Setting, error) {
+	res, err := s.repository.FindSettings(c.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (s *service) FindPubkeyBlacklists(c *cctx.Context, req *models.Blacklist) ([]string, error) {
 	fetch, err := s.repository.FindBlacklists(c.GetDatabase(), req)
 	if err != nil {
@@ -115,57 +265,191 @@ func (s *service) FindPubkeyBlacklists(c *cctx.Context, req *models.Blacklist) (
 
 	res := []string{}
 	for _, v := range fetch {
+		// รายการแบน event id หรือ kind ไม่มี pubkey
+		if v.Pubkey == "" {
+			continue
+		}
 		res = append(res, v.Pubkey)
 	}
 
 	return res, nil
 }
 
-func (s *service) ClearEventsWithBlacklist(c *cctx.Context) error {
+// ClearEventsWithBlacklist ลบ event ของ pubkey ที่ถูกแบน
+// return event ที่ถูกลบ
+func (s *service) ClearEventsWithBlacklist(c *cctx.Context) ([]*models.Event, error) {
 	// find blacklists
 	blacklists, err := s.FindPubkeyBlacklists(c, &models.Blacklist{})
 	if err != nil {
 		logger.Log.Errorf("find blacklist error: %s", err)
-		return err
+		return nil, err
 	}
 
 	if generic.IsEmpty(blacklists) {
-		return nil
+		return nil, nil
 	}
 
 	// find event
 	fetch, err := s.FindAll(c, &Request{NostrFilter: &models.Filter{Authors: blacklists}, NoLimit: true})
 	if err != nil {
 		logger.Log.Errorf("find event with blacklist error: %s", err)
-		return err
+		return nil, err
 	}
 
 	// delete event
+	res := make([]*models.Event, 0, len(fetch))
 	for _, v := range fetch {
 		err := s.repository.SoftDelete(c.GetDatabase(), &models.Event{ID: v.ID})
 		if err != nil {
 			logger.Log.Errorf("soft delete event with blacklist error: %s", err)
-			return err
+			return res, err
 		}
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// ClearEventsWithPubkey ลบ event ทั้งหมดของ pubkey
+// return event ที่ถูกลบ (เฉพาะ kind และ tags)
+func (s *service) ClearEventsWithPubkey(c *cctx.Context, pubkey string) ([]*models.Event, error) {
+	res, err := s.repository.SoftDeleteByPubkey(c.GetDatabase(), pubkey)
+	if err != nil {
+		logger.Log.Errorf("soft delete event with pubkey error: %s", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ClearEventsExpiration ลบ event ที่หมดอายุ (NIP-40)
+// return event ที่ถูกลบ (เฉพาะ kind และ tags)
+func (s *service) ClearEventsExpiration(c *cctx.Context) ([]*models.Event, error) {
+	res, err := s.repository.DeleteEventsExpired(c.GetDatabase(), utils.Now().Unix())
+	if err != nil {
+		logger.Log.Errorf("delete event with expiration error: %s", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) InsertBlockIP(c *cctx.Context, req *models.BlockIP) error {
+	err := s.repository.InsertBlockIP(c.GetDatabase(), req)
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (s *service) ClearEventsExpiration(c *cctx.Context) error {
-	fetch, err := s.repository.FindEventsExpiration(c.GetDatabase())
+func (s *service) FindBlockIP(c *cctx.Context, ip string) (*models.BlockIP, error) {
+	res, err := s.repository.FindBlockIP(c.GetDatabase(), ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) FindBlockIPs(c *cctx.Context) ([]*models.BlockIP, error) {
+	res, err := s.repository.FindBlockIPs(c.GetDatabase())
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) DeleteBlockIP(c *cctx.Context, ip string) error {
+	err := s.repository.DeleteBlockIP(c.GetDatabase(), ip)
 	if err != nil {
-		logger.Log.Errorf("find event with expiration error: %s", err)
 		return err
 	}
 
-	// delete event
-	for _, v := range fetch {
-		err := s.repository.SoftDelete(c.GetDatabase(), &models.Event{ID: v.ID})
-		if err != nil {
-			logger.Log.Errorf("soft delete event with expiration error: %s", err)
-			return err
-		}
+	return nil
+}
+
+// ClearBlockIPsExpired ลบรายการ block ip ที่หมดอายุนานเกิน block ip max duration
+// (เก็บไว้ช่วงหนึ่งเพื่อใช้เพิ่มระยะเวลา block กรณีโดนซ้ำ)
+func (s *service) ClearBlockIPsExpired(c *cctx.Context) error {
+	before := utils.Now().Add(-s.config.App.RateLimit.BlockIPMaxDuration)
+	err := s.repository.DeleteBlockIPsExpired(c.GetDatabase(), before)
+	if err != nil {
+		logger.Log.Errorf("delete block ip expired error: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindHLLs(c *cctx.Context, keys []string) ([]*models.HLLCount, error) {
+	res, err := s.repository.FindHLLs(c.GetDatabase(), keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) MergeHLLs(c *cctx.Context, req []*models.HLLCount) error {
+	err := s.repository.MergeHLLs(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) DeleteHLLs(c *cctx.Context, keys []string) error {
+	err := s.repository.DeleteHLLs(c.GetDatabase(), keys)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) InsertDeletions(c *cctx.Context, req []*models.Deletion) error {
+	err := s.repository.InsertDeletions(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) IsDeleted(c *cctx.Context, req *models.Event) (bool, error) {
+	res, err := s.repository.IsDeleted(c.GetDatabase(), req)
+	if err != nil {
+		return false, err
+	}
+
+	return res, nil
+}
+
+func (s *service) InsertVanish(c *cctx.Context, req *models.Vanish) error {
+	err := s.repository.InsertVanish(c.GetDatabase(), req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindVanish(c *cctx.Context, pubkey string) (*models.Vanish, error) {
+	res, err := s.repository.FindVanish(c.GetDatabase(), pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) DeleteEventsVanish(c *cctx.Context, req *models.Vanish) error {
+	err := s.repository.DeleteEventsVanish(c.GetDatabase(), req)
+	if err != nil {
+		return err
 	}
 
 	return nil
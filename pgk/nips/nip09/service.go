@@ -11,106 +11,169 @@ import (
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
 	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
 )
 
+// KindDeletion kind ของคำขอลบ event
+const KindDeletion = 5
+
 // Service service interface
 type Service interface {
+	Validate(evt *models.Event) error
 	CancelEvent(c *cctx.Context, evt *models.Event) error
+	IsDeleted(c *cctx.Context, evt *models.Event) (bool, error)
 }
 
 type service struct {
 	config     *config.Configs
 	eventstore eventstore.Service
+	nip45      nip45.Service
 }
 
 func NewService() Service {
 	return &service{
 		config:     config.CF,
 		eventstore: eventstore.NewService(),
+		nip45:      nip45.NewService(),
 	}
 }
 
-// CancelEvent soft delete event
+// Validate check คำขอลบ event ก่อนจัดเก็บ
+func (s *service) Validate(evt *models.Event) error {
+	_, _, err := parseDeletion(evt)
+	return err
+}
+
+// CancelEvent soft delete event และบันทึก tombstone
+// เพื่อไม่ให้ event ที่ถูกลบถูกส่งกลับเข้ามาใหม่
 func (s *service) CancelEvent(c *cctx.Context, evt *models.Event) error {
-	if generic.IsEmpty(evt) {
-		return errors.New("invalid: event not found")
+	deletions, filters, err := parseDeletion(evt)
+	if err != nil {
+		return err
 	}
 
-	if evt.Pubkey == "" {
-		return errors.New("invalid: missing 'pubkey' on parameterized deletion event")
+	// tombstone
+	err = s.eventstore.InsertDeletions(c, deletions)
+	if err != nil {
+		logger.Log.Errorf("insert deletions error: %s", err)
+		return errors.New("error: could not connect to the database")
 	}
 
-	filter := &models.Filter{}
+	var deleted []*models.Event
+	for _, filter := range filters {
+		// มองตามผู้สร้าง event
+		filter.Authors = []string{evt.Pubkey}
 
-	// หา event id จาก tag "e"
-	var ids []string
-	tags := evt.Tags.FindAll("e")
-	for _, v := range *tags {
-		ids = append(ids, v.Value())
-	}
+		// find event
+		fetch, err := s.eventstore.FindAll(c, &eventstore.Request{NostrFilter: filter, NoLimit: true})
+		if err != nil {
+			logger.Log.Errorf("find event error: %s", err)
+			return errors.New("error: could not connect to the database")
+		}
 
-	if len(ids) > 0 {
-		filter.IDs = ids
-	} else {
-		// หา filter จาก tag "a"
-		tag := evt.Tags.FindFirst("a")
-		if tag != nil && len(*tag) >= 2 {
-			v := strings.Split(tag.Value(), ":")
-			if len(v) == 3 {
-				kind, _ := strconv.Atoi(v[0])
-				author := v[1]
-				identifier := v[2]
-				filter.Kinds = []int{kind}
-				filter.Authors = []string{author}
-				filter.Tags = models.TagMap{"d": []string{identifier}}
-				filter.Until = &evt.CreatedAt
+		// cancel event
+		for _, v := range fetch {
+			// คำขอลบ (kind 5) ไม่สามารถถูกลบได้
+			if v.Kind == KindDeletion {
+				continue
+			}
+
+			// coordinate ของ event ที่มี d ต่างกันจะไม่ถูกลบ
+			if filter.Tags != nil && v.Tags.FindKeyD() != filter.Tags["#d"][0] {
+				continue
 			}
+
+			err := s.eventstore.SoftDelete(c, &models.Event{ID: v.ID})
+			if err != nil {
+				logger.Log.Errorf("soft delete error: %s", err)
+				return errors.New("error: could not connect to the database")
+			}
+			deleted = append(deleted, v)
 		}
 	}
 
-	if generic.IsEmpty(filter) {
-		return errors.New("invalid: tags e or a not found")
+	// ให้ COUNT คำนวณ register ใหม่โดยไม่นับ event ที่ถูกลบ
+	err = s.nip45.InvalidateEvents(c, deleted)
+	if err != nil {
+		logger.Log.Errorf("invalidate hll error: %s", err)
 	}
 
-	if generic.IsEmpty(filter.Kinds) {
-		// หา kind จาก tag "k"
-		var kinds []int
-		tags = evt.Tags.FindAll("k")
-		for _, v := range *tags {
-			i, _ := strconv.Atoi(v.Value())
-			kinds = append(kinds, i)
-		}
-		if len(kinds) > 0 {
-			filter.Kinds = kinds
-		}
+	return nil
+}
+
+// parseDeletion แยก tombstone และ filter ของ event ที่ต้องลบจากคำขอ
+func parseDeletion(evt *models.Event) ([]*models.Deletion, []*models.Filter, error) {
+	if generic.IsEmpty(evt) {
+		return nil, nil, errors.New("invalid: event not found")
 	}
 
-	if generic.IsEmpty(filter) {
-		return errors.New("invalid: filter not found")
+	if evt.Pubkey == "" {
+		return nil, nil, errors.New("invalid: missing 'pubkey' on deletion event")
 	}
 
-	// มองตามผู้สร้าง event
-	filter.Authors = []string{evt.Pubkey}
+	// หา kind จาก tag "k"
+	var kinds []int
+	for _, v := range *evt.Tags.FindAll("k") {
+		i, err := strconv.Atoi(v.Value())
+		if err == nil {
+			kinds = append(kinds, i)
+		}
+	}
 
-	// find event
-	fetch, err := s.eventstore.FindAll(c, &eventstore.Request{NostrFilter: filter, NoLimit: true})
-	if err != nil {
-		logger.Log.Errorf("find event error: %s", err)
-		return errors.New("error: could not connect to the database")
+	var deletions []*models.Deletion
+	var filters []*models.Filter
+
+	// หา event id จาก tag "e"
+	var ids []string
+	for _, v := range *evt.Tags.FindAll("e") {
+		if v.Value() == "" {
+			continue
+		}
+		ids = append(ids, v.Value())
+		deletions = append(deletions, &models.Deletion{DeletionID: evt.ID, Pubkey: evt.Pubkey, EventID: v.Value()})
+	}
+	if len(ids) > 0 {
+		filters = append(filters, &models.Filter{IDs: ids, Kinds: kinds})
 	}
 
-	// cancel event
-	for _, v := range fetch {
-		if v.Pubkey != evt.Pubkey {
-			return errors.New("blocked: you are not the author of this event")
+	// หา coordinate จาก tag "a" (kind:pubkey:d)
+	for _, v := range *evt.Tags.FindAll("a") {
+		address := strings.SplitN(v.Value(), ":", 3)
+		if len(address) != 3 {
+			continue
 		}
 
-		err := s.eventstore.SoftDelete(c, &models.Event{ID: v.ID})
+		kind, err := strconv.Atoi(address[0])
 		if err != nil {
-			logger.Log.Errorf("soft delete error: %s", err)
-			return errors.New("error: could not connect to the database")
+			continue
+		}
+
+		// ลบได้เฉพาะ event ของตัวเอง
+		if address[1] != evt.Pubkey {
+			continue
 		}
+
+		deletions = append(deletions, &models.Deletion{DeletionID: evt.ID, Pubkey: evt.Pubkey, Address: v.Value(), Until: evt.CreatedAt})
+
+		filter := &models.Filter{Kinds: []int{kind}, Until: &evt.CreatedAt}
+		if kind >= 30000 && kind < 40000 {
+			filter.Tags = models.TagMap{"#d": []string{address[2]}}
+		}
+		filters = append(filters, filter)
 	}
 
-	return nil
+	if len(deletions) == 0 {
+		return nil, nil, errors.New("invalid: tags e or a not found")
+	}
+
+	return deletions, filters, nil
+}
+
+// IsDeleted check event ถูกลบโดยผู้สร้างแล้ว
+func (s *service) IsDeleted(c *cctx.Context, evt *models.Event) (bool, error) {
+	if evt.Kind == KindDeletion {
+		return false, nil
+	}
+
+	return s.eventstore.IsDeleted(c, evt)
 }
@@ -0,0 +1,154 @@
+package nip09
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+)
+
+// store eventstore สำหรับทดสอบ เก็บ event และ tombstone ในหน่วยความจำ
+type store struct {
+	eventstore.Service
+	events    []*models.Event
+	deletions []*models.Deletion
+	deleted   []string
+}
+
+func (s *store) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	var res []*models.Event
+	for _, evt := range s.events {
+		if req.NostrFilter.Matches(evt) {
+			res = append(res, evt)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *store) SoftDelete(c *cctx.Context, req *models.Event) error {
+	s.deleted = append(s.deleted, req.ID)
+	return nil
+}
+
+func (s *store) InsertDeletions(c *cctx.Context, req []*models.Deletion) error {
+	s.deletions = append(s.deletions, req...)
+	return nil
+}
+
+func (s *store) IsDeleted(c *cctx.Context, req *models.Event) (bool, error) {
+	return true, nil
+}
+
+// hll nip45 สำหรับทดสอบ ไม่ทำอะไร
+type hll struct {
+	nip45.Service
+}
+
+func (hll) InvalidateEvents(c *cctx.Context, events []*models.Event) error {
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	s := &service{}
+	tests := []struct {
+		name  string
+		evt   *models.Event
+		valid bool
+	}{
+		{"e tag", &models.Event{Kind: KindDeletion, Pubkey: "alice", Tags: models.Tags{{"e", "id1"}}}, true},
+		{"a tag", &models.Event{Kind: KindDeletion, Pubkey: "alice", Tags: models.Tags{{"a", "30023:alice:post"}}}, true},
+		{"a tag of other author", &models.Event{Kind: KindDeletion, Pubkey: "alice", Tags: models.Tags{{"a", "30023:bob:post"}}}, false},
+		{"invalid a tag", &models.Event{Kind: KindDeletion, Pubkey: "alice", Tags: models.Tags{{"a", "post"}}}, false},
+		{"empty e tag", &models.Event{Kind: KindDeletion, Pubkey: "alice", Tags: models.Tags{{"e", ""}}}, false},
+		{"no tags", &models.Event{Kind: KindDeletion, Pubkey: "alice"}, false},
+		{"missing pubkey", &models.Event{Kind: KindDeletion, Tags: models.Tags{{"e", "id1"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.evt)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCancelEvent(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+
+	events := []*models.Event{
+		{ID: "note", Pubkey: "alice", Kind: 1, CreatedAt: 10},
+		{ID: "other", Pubkey: "bob", Kind: 1, CreatedAt: 10},
+		{ID: "deletion", Pubkey: "alice", Kind: KindDeletion, CreatedAt: 10},
+		{ID: "post", Pubkey: "alice", Kind: 30023, CreatedAt: 10, Tags: models.Tags{{"d", "post"}}},
+		{ID: "draft", Pubkey: "alice", Kind: 30023, CreatedAt: 10, Tags: models.Tags{{"d", "draft"}}},
+		{ID: "newer", Pubkey: "alice", Kind: 30023, CreatedAt: 30, Tags: models.Tags{{"d", "post"}}},
+	}
+
+	tests := []struct {
+		name      string
+		tags      models.Tags
+		deleted   []string
+		deletions []*models.Deletion
+	}{
+		{
+			name:      "e tag deletes own event only",
+			tags:      models.Tags{{"e", "note"}, {"e", "other"}},
+			deleted:   []string{"note"},
+			deletions: []*models.Deletion{{DeletionID: "req", Pubkey: "alice", EventID: "note"}, {DeletionID: "req", Pubkey: "alice", EventID: "other"}},
+		},
+		{
+			name:      "kind 5 is not deletable",
+			tags:      models.Tags{{"e", "deletion"}},
+			deleted:   nil,
+			deletions: []*models.Deletion{{DeletionID: "req", Pubkey: "alice", EventID: "deletion"}},
+		},
+		{
+			name:      "a tag deletes matching d until created_at",
+			tags:      models.Tags{{"a", "30023:alice:post"}},
+			deleted:   []string{"post"},
+			deletions: []*models.Deletion{{DeletionID: "req", Pubkey: "alice", Address: "30023:alice:post", Until: 20}},
+		},
+		{
+			name:      "a tag of other author is ignored",
+			tags:      models.Tags{{"a", "30023:bob:post"}, {"e", "note"}},
+			deleted:   []string{"note"},
+			deletions: []*models.Deletion{{DeletionID: "req", Pubkey: "alice", EventID: "note"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &store{events: events}
+			s := &service{eventstore: st, nip45: hll{}}
+
+			err := s.CancelEvent(&cctx.Context{}, &models.Event{ID: "req", Pubkey: "alice", Kind: KindDeletion, CreatedAt: 20, Tags: tt.tags})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.deleted, st.deleted)
+			assert.ElementsMatch(t, tt.deletions, st.deletions)
+		})
+	}
+}
+
+func TestIsDeleted(t *testing.T) {
+	s := &service{eventstore: &store{}}
+
+	// คำขอลบ (kind 5) ไม่ถูกลบด้วยคำขอลบอื่น
+	deleted, err := s.IsDeleted(&cctx.Context{}, &models.Event{Kind: KindDeletion})
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = s.IsDeleted(&cctx.Context{}, &models.Event{Kind: 1})
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+}
@@ -0,0 +1,62 @@
+package nip13
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptive วัดอัตรา event ที่เข้ามาทั้งรีเลย์ เพื่อเพิ่มความยากของ pow ตามโหลด
+type adaptive struct {
+	mu     sync.Mutex
+	start  time.Time
+	count  int64
+	rate   float64
+	window time.Duration
+}
+
+var load = &adaptive{}
+
+// observe นับ event ที่เข้ามา
+func (a *adaptive) observe(now time.Time, window time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.roll(now, window)
+	a.count++
+}
+
+// current อัตรา event ต่อวินาทีของช่วงเวลาล่าสุด
+func (a *adaptive) current(now time.Time, window time.Duration) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.roll(now, window)
+
+	return a.rate
+}
+
+// roll ปิดรอบการวัดเมื่อครบ window
+func (a *adaptive) roll(now time.Time, window time.Duration) {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+
+	if a.start.IsZero() {
+		a.start = now
+		return
+	}
+
+	elapsed := now.Sub(a.start)
+	if elapsed < window {
+		return
+	}
+
+	// ไม่มี event เกินหนึ่งรอบ ถือว่าอัตราเป็น 0
+	if elapsed >= 2*window {
+		a.rate = 0
+	} else {
+		a.rate = float64(a.count) / elapsed.Seconds()
+	}
+	a.start = now
+	a.count = 0
+}
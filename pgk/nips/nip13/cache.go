@@ -0,0 +1,54 @@
+package nip13
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/config"
+)
+
+// ระยะเวลาที่จำกลุ่มของ pubkey (known/unknown) ก่อนค้นจากฐานข้อมูลใหม่
+const classTTL = 5 * time.Minute
+
+type classEntry struct {
+	class     config.PubkeyClass
+	expiresAt time.Time
+}
+
+var (
+	// pubkey ใน allowlist ของรีเลย์ โหลดใหม่ตามรอบ cron (nil คือยังไม่เคยโหลด)
+	allowlist atomic.Pointer[map[string]struct{}]
+
+	// กลุ่มของ pubkey ที่ค้นแล้ว
+	classes sync.Map
+)
+
+// cachedClass กลุ่มของ pubkey ที่ยังไม่หมดอายุ
+func cachedClass(pubkey string, now time.Time) (config.PubkeyClass, bool) {
+	v, ok := classes.Load(pubkey)
+	if !ok {
+		return "", false
+	}
+
+	entry := v.(classEntry)
+	if now.After(entry.expiresAt) {
+		return "", false
+	}
+
+	return entry.class, true
+}
+
+func storeClass(pubkey string, class config.PubkeyClass, now time.Time) {
+	classes.Store(pubkey, classEntry{class: class, expiresAt: now.Add(classTTL)})
+}
+
+// pruneClasses ลบกลุ่มของ pubkey ที่หมดอายุ
+func pruneClasses(now time.Time) {
+	classes.Range(func(key, v any) bool {
+		if now.After(v.(classEntry).expiresAt) {
+			classes.Delete(key)
+		}
+		return true
+	})
+}
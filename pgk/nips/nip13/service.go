@@ -1,28 +1,37 @@
 package nip13
 
 import (
-	"errors"
 	"fmt"
 	"math/bits"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
 )
 
 // Service service interface
 type Service interface {
 	VerifyPow(c *cctx.Context, evt *models.Event) (bool, error)
+	MinDifficulty() int
+	Refresh(c *cctx.Context) error
+	RefreshPubkey(c *cctx.Context, pubkey string) error
 }
 
 type service struct {
-	config *config.Configs
+	config     *config.Configs
+	eventstore eventstore.Service
 }
 
 func NewService() Service {
 	return &service{
-		config: config.CF,
+		config:     config.CF,
+		eventstore: eventstore.NewService(),
 	}
 }
 
@@ -49,6 +58,10 @@ func (s *service) difficulty(hex string) int {
 
 // VerifyPow verify proof of work
 func (s *service) VerifyPow(c *cctx.Context, evt *models.Event) (bool, error) {
+	if s.config.App.Pow.Adaptive.Enable {
+		load.observe(time.Now(), s.config.App.Pow.Adaptive.Window)
+	}
+
 	work := s.difficulty(evt.ID)
 	nonceTag := evt.Tags.FindFirst("nonce")
 	if nonceTag != nil && len(*nonceTag) >= 3 {
@@ -58,9 +71,209 @@ func (s *service) VerifyPow(c *cctx.Context, evt *models.Event) (bool, error) {
 		}
 	}
 
-	if work < s.config.Info.Limitation.MinPowDifficulty {
-		return false, errors.New("insufficient difficulty")
+	required := s.required(c, evt)
+	if work < required {
+		return false, fmt.Errorf("difficulty %d is less than %d", work, required)
 	}
 
 	return true, nil
 }
+
+// MinDifficulty ความยากขั้นต่ำปัจจุบันของ pubkey ที่ไม่อยู่ใน allowlist
+// ค่าต่ำสุดจากกฎทุกข้อและ MIN_POW_DIFFICULTY รวมส่วนที่เพิ่มตามโหลด
+func (s *service) MinDifficulty() int {
+	difficulty := -1
+	fallback := true
+	for _, rule := range s.config.App.Pow.Rules {
+		if rule.Class == config.PubkeyAllowlisted {
+			continue
+		}
+
+		if difficulty < 0 || rule.Difficulty < difficulty {
+			difficulty = rule.Difficulty
+		}
+
+		// กฎที่ตรงทุก event กฎถัดไปและค่าเริ่มต้นจะไม่ถูกใช้
+		if len(rule.Kinds) == 0 && rule.Class == "" {
+			fallback = false
+			break
+		}
+	}
+
+	if fallback {
+		base := s.config.Info.Limitation.MinPowDifficulty
+		if difficulty < 0 || base < difficulty {
+			difficulty = base
+		}
+	}
+
+	return s.adaptive(difficulty)
+}
+
+// Refresh โหลด allowlist ของรีเลย์ไว้ในหน่วยความจำ และลบกลุ่มของ pubkey ที่หมดอายุ
+func (s *service) Refresh(c *cctx.Context) error {
+	pruneClasses(time.Now())
+
+	fetch, err := s.eventstore.FindAllowlists(c)
+	if err != nil {
+		logger.Log.Errorf("load pow allowlist error: %s", err)
+		return err
+	}
+
+	pubkeys := make(map[string]struct{}, len(fetch))
+	for _, v := range fetch {
+		pubkeys[v.Pubkey] = struct{}{}
+	}
+	allowlist.Store(&pubkeys)
+
+	return nil
+}
+
+// RefreshPubkey ลบกลุ่มของ pubkey ที่จำไว้และโหลด allowlist ใหม่ (ใช้เมื่อ allowlist เปลี่ยน)
+func (s *service) RefreshPubkey(c *cctx.Context, pubkey string) error {
+	classes.Delete(pubkey)
+
+	return s.Refresh(c)
+}
+
+// required ความยากที่ event ต้องมี
+// ใช้กฎแรกที่ตรงกับ kind และกลุ่มของ pubkey
+func (s *service) required(c *cctx.Context, evt *models.Event) int {
+	difficulty := s.config.Info.Limitation.MinPowDifficulty
+
+	allowlisted := s.isAllowlisted(c, evt.Pubkey)
+	var class config.PubkeyClass
+	if allowlisted {
+		class = config.PubkeyAllowlisted
+	}
+
+	for _, rule := range s.config.App.Pow.Rules {
+		if len(rule.Kinds) > 0 && !matchKinds(rule.Kinds, evt.Kind) {
+			continue
+		}
+
+		if rule.Class != "" {
+			// known/unknown ต้องค้นจากฐานข้อมูล ทำเฉพาะเมื่อจำเป็น
+			if class == "" && rule.Class != config.PubkeyAllowlisted {
+				class = s.class(c, evt.Pubkey)
+			}
+			if rule.Class != class {
+				continue
+			}
+		}
+
+		difficulty = rule.Difficulty
+		break
+	}
+
+	// allowlisted ไม่เพิ่มความยากตามโหลด
+	if allowlisted {
+		return difficulty
+	}
+
+	return s.adaptive(difficulty)
+}
+
+// class กลุ่มของ pubkey ที่ไม่อยู่ใน allowlist
+// จำผลไว้ classTTL เพื่อไม่ต้องค้นฐานข้อมูลทุก event
+func (s *service) class(c *cctx.Context, pubkey string) config.PubkeyClass {
+	now := time.Now()
+	if class, ok := cachedClass(pubkey, now); ok {
+		return class
+	}
+
+	fetch, err := s.eventstore.FindAll(c, &eventstore.Request{NostrFilter: &models.Filter{Authors: []string{pubkey}, Limit: 1}})
+	if err != nil {
+		logger.Log.Errorf("find pubkey events error: %s", err)
+		return config.PubkeyUnknown
+	}
+
+	class := config.PubkeyUnknown
+	if !generic.IsEmpty(fetch) {
+		class = config.PubkeyKnown
+	}
+	storeClass(pubkey, class, now)
+
+	return class
+}
+
+// isAllowlisted check pubkey อยู่ใน allowlist ของ pow หรือ allowlist ของรีเลย์
+func (s *service) isAllowlisted(c *cctx.Context, pubkey string) bool {
+	if slices.Contains(s.config.App.Pow.Allowlist, pubkey) {
+		return true
+	}
+
+	// ไม่มีกฎ pow ที่ต้องใช้กลุ่มของ pubkey ไม่ต้องค้นจากฐานข้อมูล
+	if len(s.config.App.Pow.Rules) == 0 && !s.config.App.Pow.Adaptive.Enable {
+		return false
+	}
+
+	// ใช้ allowlist ที่โหลดไว้ ถ้ายังไม่เคยโหลดค้นจากฐานข้อมูล
+	if pubkeys := allowlist.Load(); pubkeys != nil {
+		_, ok := (*pubkeys)[pubkey]
+		return ok
+	}
+
+	allowed, err := s.eventstore.IsAllowlisted(c, []string{pubkey})
+	if err != nil {
+		logger.Log.Errorf("find allowlist error: %s", err)
+		return false
+	}
+
+	return allowed
+}
+
+// adaptive เพิ่มความยากตามอัตรา event ที่เกิน threshold
+func (s *service) adaptive(difficulty int) int {
+	cf := s.config.App.Pow.Adaptive
+	if !cf.Enable || cf.Threshold <= 0 {
+		return difficulty
+	}
+
+	rate := load.current(time.Now(), cf.Window)
+	if rate <= cf.Threshold {
+		return difficulty
+	}
+
+	difficulty += cf.Step * int(rate/cf.Threshold)
+	if cf.Max > 0 && difficulty > cf.Max {
+		difficulty = cf.Max
+	}
+
+	return difficulty
+}
+
+// matchKinds check kind อยู่ในรายการ (kind หรือช่วง [from, to])
+func matchKinds(kinds []interface{}, kind int) bool {
+	for _, v := range kinds {
+		switch k := v.(type) {
+		case []interface{}:
+			if len(k) == 2 {
+				from, ok1 := toInt(k[0])
+				to, ok2 := toInt(k[1])
+				if ok1 && ok2 && kind >= from && kind <= to {
+					return true
+				}
+			}
+		default:
+			if i, ok := toInt(k); ok && i == kind {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch i := v.(type) {
+	case int:
+		return i, true
+	case int64:
+		return int(i), true
+	case float64:
+		return int(i), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,131 @@
+package nip13
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+// store eventstore สำหรับทดสอบ ไม่มี pubkey ใดอยู่ใน allowlist หรือมี event
+type store struct {
+	eventstore.Service
+}
+
+func (store) IsAllowlisted(c *cctx.Context, pubkeys []string) (bool, error) {
+	return false, nil
+}
+
+func (store) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	return nil, nil
+}
+
+// countStore eventstore สำหรับทดสอบ นับจำนวนการค้น event
+type countStore struct {
+	eventstore.Service
+	pubkeys []string
+	finds   int
+}
+
+func (s *countStore) FindAllowlists(c *cctx.Context) ([]*models.Allowlist, error) {
+	var res []*models.Allowlist
+	for _, pubkey := range s.pubkeys {
+		res = append(res, &models.Allowlist{Pubkey: pubkey})
+	}
+
+	return res, nil
+}
+
+func (s *countStore) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	s.finds++
+	return nil, nil
+}
+
+func TestMatchKinds(t *testing.T) {
+	kinds := []interface{}{1, []interface{}{30000, 39999}}
+	assert.True(t, matchKinds(kinds, 1))
+	assert.True(t, matchKinds(kinds, 30023))
+	assert.False(t, matchKinds(kinds, 7))
+}
+
+func TestRequired(t *testing.T) {
+	cf := &config.Configs{}
+	cf.Info.Limitation = &config.InfoLimitation{MinPowDifficulty: 8}
+	cf.App.Pow.Allowlist = []string{"allowed"}
+	cf.App.Pow.Rules = []*config.PowRule{
+		{Class: config.PubkeyAllowlisted, Difficulty: 0},
+		{Kinds: []interface{}{4}, Difficulty: 20},
+	}
+	s := &service{config: cf, eventstore: store{}}
+	c := &cctx.Context{}
+
+	assert.Equal(t, 0, s.required(c, &models.Event{Kind: 4, Pubkey: "allowed"}))
+	assert.Equal(t, 20, s.required(c, &models.Event{Kind: 4, Pubkey: "other"}))
+	assert.Equal(t, 0, s.required(c, &models.Event{Kind: 1, Pubkey: "allowed"}))
+	assert.Equal(t, 8, s.required(c, &models.Event{Kind: 1, Pubkey: "other"}))
+}
+
+func TestRequiredCache(t *testing.T) {
+	cf := &config.Configs{}
+	cf.Info.Limitation = &config.InfoLimitation{}
+	cf.App.Pow.Rules = []*config.PowRule{
+		{Class: config.PubkeyAllowlisted, Difficulty: 0},
+		{Class: config.PubkeyUnknown, Difficulty: 16},
+	}
+	st := &countStore{pubkeys: []string{"cached"}}
+	s := &service{config: cf, eventstore: st}
+	c := &cctx.Context{}
+
+	defer allowlist.Store(nil)
+	assert.NoError(t, s.Refresh(c))
+
+	// allowlist และกลุ่มของ pubkey ใช้ค่าที่จำไว้ ไม่ค้นฐานข้อมูลซ้ำ
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 0, s.required(c, &models.Event{Kind: 1, Pubkey: "cached"}))
+		assert.Equal(t, 16, s.required(c, &models.Event{Kind: 1, Pubkey: "new"}))
+	}
+	assert.Equal(t, 1, st.finds)
+
+	// allowlist เปลี่ยนผ่าน management api มีผลทันที
+	st.pubkeys = []string{"new"}
+	assert.NoError(t, s.RefreshPubkey(c, "new"))
+	assert.Equal(t, 0, s.required(c, &models.Event{Kind: 1, Pubkey: "new"}))
+	assert.Equal(t, 16, s.required(c, &models.Event{Kind: 1, Pubkey: "cached"}))
+}
+
+func TestMinDifficulty(t *testing.T) {
+	cf := &config.Configs{}
+	cf.Info.Limitation = &config.InfoLimitation{MinPowDifficulty: 8}
+	s := &service{config: cf}
+	assert.Equal(t, 8, s.MinDifficulty())
+
+	// กฎของ allowlisted ไม่นับ
+	cf.App.Pow.Rules = []*config.PowRule{
+		{Class: config.PubkeyAllowlisted, Difficulty: 0},
+		{Class: config.PubkeyKnown, Difficulty: 4},
+		{Kinds: []interface{}{4}, Difficulty: 20},
+	}
+	assert.Equal(t, 4, s.MinDifficulty())
+
+	// กฎที่ตรงทุก event แทน MIN_POW_DIFFICULTY
+	cf.App.Pow.Rules = []*config.PowRule{
+		{Kinds: []interface{}{4}, Difficulty: 20},
+		{Difficulty: 12},
+	}
+	assert.Equal(t, 12, s.MinDifficulty())
+}
+
+func TestAdaptive(t *testing.T) {
+	a := &adaptive{}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		a.observe(now, time.Second)
+	}
+	assert.InDelta(t, 1000, a.current(now.Add(time.Second), time.Second), 1)
+	assert.Equal(t, float64(0), a.current(now.Add(5*time.Second), time.Second))
+}
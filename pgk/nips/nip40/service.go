@@ -34,6 +34,11 @@ func (s *service) Expiration(c *cctx.Context, evt *models.Event) (*models.Timest
 		if expiration < 100 {
 			return utils.Pointer(models.Timestamp(expiration)), errors.New("invalid: expiration")
 		}
+
+		// event ที่หมดอายุแล้วจะไม่จัดเก็บ
+		if expiration <= utils.Now().Unix() {
+			return utils.Pointer(models.Timestamp(expiration)), errors.New("invalid: event has expired")
+		}
 	}
 
 	return utils.Pointer(models.Timestamp(expiration)), nil
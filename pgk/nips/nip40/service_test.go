@@ -0,0 +1,31 @@
+package nip40
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+func TestExpiration(t *testing.T) {
+	s := NewService()
+	c := &cctx.Context{}
+	now := utils.Now().Unix()
+
+	res, err := s.Expiration(c, &models.Event{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Timestamp(0), *res)
+
+	future := strconv.FormatInt(now+3600, 10)
+	res, err = s.Expiration(c, &models.Event{Tags: models.Tags{{"expiration", future}}})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Timestamp(now+3600), *res)
+
+	past := strconv.FormatInt(now-60, 10)
+	_, err = s.Expiration(c, &models.Event{Tags: models.Tags{{"expiration", past}}})
+	assert.EqualError(t, err, "invalid: event has expired")
+}
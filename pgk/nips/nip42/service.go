@@ -0,0 +1,82 @@
+package nip42
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+const (
+	// KindAuth kind ของ event ที่ใช้ยืนยันตัวตน
+	KindAuth = 22242
+
+	// ระยะเวลาที่ยอมรับ created_at ของ event ยืนยันตัวตน
+	authWindow = 10 * time.Minute
+)
+
+// Service service interface
+type Service interface {
+	Challenge() string
+	ValidateAuthEvent(c *cctx.Context, evt *models.Event, challenge, relayURL string) (bool, error)
+}
+
+type service struct {
+	config *config.Configs
+}
+
+func NewService() Service {
+	return &service{
+		config: config.CF,
+	}
+}
+
+// Challenge สร้าง challenge สำหรับส่งให้ client
+func (s *service) Challenge() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// ValidateAuthEvent validate auth event
+func (s *service) ValidateAuthEvent(c *cctx.Context, evt *models.Event, challenge, relayURL string) (bool, error) {
+	if evt.Kind != KindAuth {
+		return false, errors.New("invalid: auth event must be kind 22242")
+	}
+
+	now := utils.Now()
+	createdAt := time.Unix(int64(evt.CreatedAt), 0)
+	if createdAt.Before(now.Add(-authWindow)) || createdAt.After(now.Add(authWindow)) {
+		return false, errors.New("invalid: auth event created_at is too far from the current time")
+	}
+
+	tag := evt.Tags.FindFirst("challenge")
+	if tag == nil || tag.Value() != challenge {
+		return false, errors.New("invalid: challenge does not match")
+	}
+
+	tag = evt.Tags.FindFirst("relay")
+	if tag == nil || !utils.IsSameRelayURL(tag.Value(), relayURL) {
+		return false, errors.New("invalid: relay url does not match")
+	}
+
+	if evt.GetID() != evt.ID {
+		return false, errors.New("invalid: event id is computed incorrectly")
+	}
+
+	ok, err := evt.VerifySignature()
+	if err != nil {
+		return false, errors.New("error: failed to verify signature")
+	}
+	if !ok {
+		return false, errors.New("invalid: signature is invalid")
+	}
+
+	return true, nil
+}
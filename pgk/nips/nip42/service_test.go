@@ -0,0 +1,56 @@
+package nip42
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+func signEvent(t *testing.T, evt *models.Event) {
+	sk, err := btcec.NewPrivateKey()
+	assert.NoError(t, err)
+
+	evt.Pubkey = hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey()))
+	evt.ID = evt.GetID()
+
+	hash := sha256.Sum256([]byte(evt.Serialize()))
+	sig, err := schnorr.Sign(sk, hash[:])
+	assert.NoError(t, err)
+	evt.Sig = hex.EncodeToString(sig.Serialize())
+}
+
+func TestValidateAuthEvent(t *testing.T) {
+	s := NewService()
+	challenge := s.Challenge()
+
+	evt := &models.Event{
+		CreatedAt: models.Timestamp(utils.Now().Unix()),
+		Kind:      KindAuth,
+		Tags:      models.Tags{{"relay", "wss://relay.example.com/"}, {"challenge", challenge}},
+	}
+	signEvent(t, evt)
+
+	ok, err := s.ValidateAuthEvent(cctx.New(), evt, challenge, "ws://Relay.example.com")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.ValidateAuthEvent(cctx.New(), evt, s.Challenge(), "wss://relay.example.com")
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	ok, err = s.ValidateAuthEvent(cctx.New(), evt, challenge, "wss://other.example.com")
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	evt.Sig = evt.Sig[:len(evt.Sig)-2] + "00"
+	ok, _ = s.ValidateAuthEvent(cctx.New(), evt, challenge, "wss://relay.example.com")
+	assert.False(t, ok)
+}
@@ -0,0 +1,149 @@
+package nip45
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// HLLRegisters จำนวน register ของ HyperLogLog ตาม NIP-45
+const HLLRegisters = 256
+
+// tag ที่รองรับการนับแบบ HyperLogLog
+var hllTags = map[string]bool{
+	"#e": true,
+	"#p": true,
+}
+
+// HLL HyperLogLog ตาม NIP-45
+type HLL struct {
+	offset    int
+	registers []byte
+}
+
+// NewHLL new hll
+func NewHLL(offset int) *HLL {
+	return &HLL{
+		offset:    offset,
+		registers: make([]byte, HLLRegisters),
+	}
+}
+
+// NewHLLWithRegisters new hll from registers
+func NewHLLWithRegisters(offset int, registers []byte) *HLL {
+	h := NewHLL(offset)
+	copy(h.registers, registers)
+
+	return h
+}
+
+// HLLOffset offset จากตัวอักษรตำแหน่งที่ 32 ของ tag value (hex) บวก 8
+func HLLOffset(value string) (int, error) {
+	if len(value) != 64 {
+		return 0, fmt.Errorf("invalid hll value length: %d", len(value))
+	}
+
+	b, err := hex.DecodeString("0" + value[32:33])
+	if err != nil {
+		return 0, err
+	}
+
+	return int(b[0]) + 8, nil
+}
+
+// HLLFilter check filter นับแบบ HyperLogLog ได้หรือไม่
+// ต้องมี kind เดียว และ tag (#e หรือ #p) ค่าเดียวเท่านั้น
+func HLLFilter(f *models.Filter) (key string, offset int, ok bool) {
+	if len(f.Kinds) != 1 || len(f.Tags) != 1 ||
+		len(f.IDs) > 0 || len(f.Authors) > 0 ||
+		f.Since != nil || f.Until != nil || f.Search != "" || f.Limit > 0 {
+		return "", 0, false
+	}
+
+	for tag, values := range f.Tags {
+		if !hllTags[tag] || len(values) != 1 {
+			return "", 0, false
+		}
+
+		offset, err := HLLOffset(values[0])
+		if err != nil {
+			return "", 0, false
+		}
+
+		return HLLKey(f.Kinds[0], tag[1:], values[0]), offset, true
+	}
+
+	return "", 0, false
+}
+
+// HLLKey key ของ register ที่จัดเก็บ
+func HLLKey(kind int, tag, value string) string {
+	return fmt.Sprintf("%d:%s:%s", kind, tag, value)
+}
+
+// Add เพิ่ม pubkey
+func (h *HLL) Add(pubkey string) {
+	pk, err := hex.DecodeString(pubkey)
+	if err != nil || len(pk) != 32 {
+		return
+	}
+
+	x := pk[h.offset : h.offset+8]
+	ri := x[0]
+	w := binary.BigEndian.Uint64(x)
+
+	// นับ bit 0 นำหน้าของ 56 bit ที่เหลือ (ไม่รวม byte แรกที่ใช้เป็น register)
+	var zeros uint8
+	for m := uint64(1 << 55); m != 0 && w&m == 0; m >>= 1 {
+		zeros++
+	}
+	if zeros+1 > h.registers[ri] {
+		h.registers[ri] = zeros + 1
+	}
+}
+
+// Merge รวม register (union)
+func (h *HLL) Merge(other *HLL) {
+	for i, v := range other.registers {
+		if v > h.registers[i] {
+			h.registers[i] = v
+		}
+	}
+}
+
+// Registers registers
+func (h *HLL) Registers() []byte {
+	return h.registers
+}
+
+// Hex registers ในรูปแบบ hex
+func (h *HLL) Hex() string {
+	return hex.EncodeToString(h.registers)
+}
+
+// Estimate ประมาณจำนวน
+func (h *HLL) Estimate() int64 {
+	m := float64(HLLRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	var sum float64
+	var zeros int
+	for _, v := range h.registers {
+		sum += math.Pow(2, -float64(v))
+		if v == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+
+	// จำนวนน้อยใช้ linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
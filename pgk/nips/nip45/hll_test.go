@@ -0,0 +1,67 @@
+package nip45
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/models"
+)
+
+func randomPubkey() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func TestHLLOffset(t *testing.T) {
+	value := strings.Repeat("0", 32) + "f" + strings.Repeat("0", 31)
+	offset, err := HLLOffset(value)
+	assert.NoError(t, err)
+	assert.Equal(t, 23, offset)
+
+	_, err = HLLOffset("abc")
+	assert.Error(t, err)
+}
+
+func TestHLLFilter(t *testing.T) {
+	id := randomPubkey()
+
+	key, _, ok := HLLFilter(&models.Filter{Kinds: []int{7}, Tags: models.TagMap{"#e": []string{id}}})
+	assert.True(t, ok)
+	assert.Equal(t, "7:e:"+id, key)
+
+	_, _, ok = HLLFilter(&models.Filter{Kinds: []int{7}, Tags: models.TagMap{"#e": []string{id, id}}})
+	assert.False(t, ok)
+
+	_, _, ok = HLLFilter(&models.Filter{Kinds: []int{7}, Authors: []string{id}, Tags: models.TagMap{"#e": []string{id}}})
+	assert.False(t, ok)
+}
+
+func TestHLLEstimate(t *testing.T) {
+	a := NewHLL(16)
+	b := NewHLL(16)
+	for i := 0; i < 5000; i++ {
+		pk := randomPubkey()
+		a.Add(pk)
+		a.Add(pk)
+		if i%2 == 0 {
+			b.Add(pk)
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		b.Add(randomPubkey())
+	}
+
+	// error มาตรฐานของ 256 register ประมาณ 6.5%
+	assert.InDelta(t, 5000, a.Estimate(), 5000*0.25)
+
+	a.Merge(b)
+	assert.InDelta(t, 10000, a.Estimate(), 10000*0.25)
+	assert.Len(t, a.Hex(), HLLRegisters*2)
+
+	assert.Equal(t, int64(0), NewHLL(8).Estimate())
+}
@@ -1,15 +1,49 @@
 package nip45
 
 import (
+	"slices"
+	"sync"
+
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
 	"github.com/saveblush/reraw-relay/pgk/eventstore"
 )
 
+// kind และ tag ที่จัดเก็บ register ของ HyperLogLog ไว้ตอนบันทึก event
+// เช่น ผู้ติดตาม (kind 3, #p), reaction (kind 7, #e), repost (kind 6, 16, #e)
+// register เพิ่มได้อย่างเดียว เมื่อ event ถูกลบ หมดอายุ หรือ vanish จะลบ register ทิ้งแล้วคำนวณใหม่
+var hllKinds = map[int][]string{
+	3:  {"p"},
+	6:  {"e"},
+	7:  {"e"},
+	16: {"e"},
+}
+
+// backfill การคำนวณ register จาก event เดิมของ key
+type backfill struct {
+	// register ถูกลบระหว่างคำนวณ ผลที่ได้อาจนับ event ที่ถูกลบไปแล้ว (ป้องกันด้วย backfillMu)
+	stale bool
+}
+
+var (
+	// key ที่กำลังคำนวณ register จาก event เดิม
+	backfilling sync.Map
+
+	// ป้องกันการบันทึกผลคำนวณพร้อมกับการลบ register
+	backfillMu sync.Mutex
+
+	// คำนวณ register จาก event เดิมได้ครั้งละ 1 key
+	backfillSlot = make(chan struct{}, 1)
+)
+
 // Service service interface
 type Service interface {
-	CountEvent(c *cctx.Context, req *models.Filter) (*int64, error)
+	CountEvents(c *cctx.Context, filters *models.Filters) (*models.CountResult, error)
+	StoreEvent(c *cctx.Context, evt *models.Event) error
+	InvalidateEvents(c *cctx.Context, events []*models.Event) error
+	InvalidatePubkey(c *cctx.Context, pubkey string, until models.Timestamp) error
 }
 
 type service struct {
@@ -24,16 +58,227 @@ func NewService() Service {
 	}
 }
 
-func (s *service) CountEvent(c *cctx.Context, req *models.Filter) (*int64, error) {
-	var noLimit bool
-	if req.Limit == 0 {
-		noLimit = true
+// CountEvents นับ event ตาม filters แบบ union (event ที่ตรงหลาย filter นับครั้งเดียว)
+func (s *service) CountEvents(c *cctx.Context, filters *models.Filters) (*models.CountResult, error) {
+	hll, err := s.countHLL(c, filters)
+	if err != nil {
+		return nil, err
+	}
+	if hll != nil {
+		return &models.CountResult{
+			Count:       hll.Estimate(),
+			Approximate: true,
+			HLL:         hll.Hex(),
+		}, nil
 	}
 
-	res, err := s.eventstore.Count(c, &eventstore.Request{NostrFilter: req, DoCount: true, NoLimit: noLimit})
+	var reqs []*eventstore.Request
+	for _, filter := range *filters {
+		reqs = append(reqs, &eventstore.Request{NostrFilter: &filter, DoCount: true, NoLimit: true})
+	}
+
+	var count *int64
+	if len(reqs) == 1 {
+		count, err = s.eventstore.Count(c, reqs[0])
+	} else {
+		for _, req := range reqs {
+			req.DoCount = false
+			req.DoIDs = true
+		}
+		count, err = s.eventstore.CountUnion(c, reqs)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	res := &models.CountResult{}
+	if count != nil {
+		res.Count = *count
+	}
+
 	return res, nil
 }
+
+// countHLL นับด้วย HyperLogLog
+// ใช้ได้เมื่อทุก filter เป็นรูปแบบที่จัดเก็บ register ไว้และมี offset เดียวกัน
+// return nil เมื่อใช้ไม่ได้ หรือ register ยังคำนวณจาก event เดิมไม่เสร็จ (ใช้การนับจริงแทน)
+func (s *service) countHLL(c *cctx.Context, filters *models.Filters) (*HLL, error) {
+	if len(*filters) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(*filters))
+	offset := -1
+	for _, filter := range *filters {
+		key, o, ok := HLLFilter(&filter)
+		if !ok || !isHLLStored(&filter) || (offset >= 0 && o != offset) {
+			return nil, nil
+		}
+		keys = append(keys, key)
+		offset = o
+	}
+
+	fetch, err := s.eventstore.FindHLLs(c, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]*models.HLLCount, len(fetch))
+	for _, v := range fetch {
+		stored[v.Key] = v
+	}
+
+	hll := NewHLL(offset)
+	ready := true
+	for idx, key := range keys {
+		v, ok := stored[key]
+		if !ok || !v.Backfilled {
+			// ยังไม่เคยคำนวณจาก event ที่มีอยู่เดิม คำนวณเบื้องหลัง
+			s.backfillHLL((*filters)[idx], key, offset)
+			ready = false
+			continue
+		}
+
+		hll.Merge(NewHLLWithRegisters(offset, v.Registers))
+	}
+
+	if !ready {
+		return nil, nil
+	}
+
+	return hll, nil
+}
+
+// backfillHLL คำนวณ register จาก event ที่จัดเก็บอยู่และบันทึกไว้ (ทำงานเบื้องหลัง)
+// ถ้ามีการคำนวณอื่นทำงานอยู่จะข้ามไป และคำนวณใหม่เมื่อมีการนับครั้งถัดไป
+func (s *service) backfillHLL(filter models.Filter, key string, offset int) {
+	state := &backfill{}
+	if _, loaded := backfilling.LoadOrStore(key, state); loaded {
+		return
+	}
+
+	select {
+	case backfillSlot <- struct{}{}:
+	default:
+		backfilling.Delete(key)
+		return
+	}
+
+	go func() {
+		defer func() {
+			<-backfillSlot
+			backfilling.Delete(key)
+		}()
+
+		c := cctx.New()
+		pubkeys, err := s.eventstore.FindPubkeys(c, &eventstore.Request{NostrFilter: &filter, DoPubkeys: true, NoLimit: true})
+		if err != nil {
+			logger.Log.Errorf("backfill hll %s error: %s", key, err)
+			return
+		}
+
+		hll := NewHLL(offset)
+		for _, pk := range pubkeys {
+			hll.Add(pk)
+		}
+
+		// register ถูกลบระหว่างคำนวณ ทิ้งผลและคำนวณใหม่เมื่อมีการนับครั้งถัดไป
+		backfillMu.Lock()
+		defer backfillMu.Unlock()
+		if state.stale {
+			return
+		}
+
+		err = s.eventstore.MergeHLLs(c, []*models.HLLCount{{Key: key, Registers: hll.Registers(), Backfilled: true}})
+		if err != nil {
+			logger.Log.Errorf("backfill hll %s error: %s", key, err)
+		}
+	}()
+}
+
+// StoreEvent อัปเดต register ของ HyperLogLog จาก event ที่บันทึกใหม่
+func (s *service) StoreEvent(c *cctx.Context, evt *models.Event) error {
+	var req []*models.HLLCount
+	for key, offset := range hllKeys(evt) {
+		hll := NewHLL(offset)
+		hll.Add(evt.Pubkey)
+		req = append(req, &models.HLLCount{Key: key, Registers: hll.Registers()})
+	}
+
+	return s.eventstore.MergeHLLs(c, req)
+}
+
+// InvalidateEvents ลบ register ที่ event ที่ถูกลบเคยนับไว้ เพื่อคำนวณใหม่จาก event ที่เหลือ
+func (s *service) InvalidateEvents(c *cctx.Context, events []*models.Event) error {
+	var keys []string
+	for _, evt := range events {
+		for key := range hllKeys(evt) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// แจ้ง backfill ที่กำลังคำนวณ key เดียวกันไม่ให้บันทึกผลทับ
+	backfillMu.Lock()
+	for _, key := range keys {
+		if v, ok := backfilling.Load(key); ok {
+			v.(*backfill).stale = true
+		}
+	}
+	backfillMu.Unlock()
+
+	return s.eventstore.DeleteHLLs(c, keys)
+}
+
+// InvalidatePubkey ลบ register ที่ event ของ pubkey จนถึง until เคยนับไว้ (ใช้ก่อนลบ event ของ pubkey)
+func (s *service) InvalidatePubkey(c *cctx.Context, pubkey string, until models.Timestamp) error {
+	kinds := make([]int, 0, len(hllKinds))
+	for kind := range hllKinds {
+		kinds = append(kinds, kind)
+	}
+
+	fetch, err := s.eventstore.FindAll(c, &eventstore.Request{
+		NostrFilter: &models.Filter{Authors: []string{pubkey}, Kinds: kinds, Until: &until},
+		NoLimit:     true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.InvalidateEvents(c, fetch)
+}
+
+// hllKeys key และ offset ของ register ที่ event นับอยู่
+func hllKeys(evt *models.Event) map[string]int {
+	tags, ok := hllKinds[evt.Kind]
+	if !ok {
+		return nil
+	}
+
+	res := make(map[string]int)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || !slices.Contains(tags, tag[0]) {
+			continue
+		}
+
+		offset, err := HLLOffset(tag[1])
+		if err != nil {
+			continue
+		}
+
+		res[HLLKey(evt.Kind, tag[0], tag[1])] = offset
+	}
+
+	return res
+}
+
+// isHLLStored check filter เป็นรูปแบบที่จัดเก็บ register ไว้
+func isHLLStored(f *models.Filter) bool {
+	for tag := range f.Tags {
+		return slices.Contains(hllKinds[f.Kinds[0]], tag[1:])
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package nip45
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+// store eventstore สำหรับทดสอบ เก็บ register ในหน่วยความจำ
+type store struct {
+	eventstore.Service
+	mu      sync.Mutex
+	pubkeys []string
+	hlls    map[string]*models.HLLCount
+}
+
+func (s *store) Count(c *cctx.Context, req *eventstore.Request) (*int64, error) {
+	count := int64(len(s.pubkeys))
+	return &count, nil
+}
+
+func (s *store) FindPubkeys(c *cctx.Context, req *eventstore.Request) ([]string, error) {
+	return s.pubkeys, nil
+}
+
+func (s *store) FindHLLs(c *cctx.Context, keys []string) ([]*models.HLLCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []*models.HLLCount
+	for _, key := range keys {
+		if v, ok := s.hlls[key]; ok {
+			res = append(res, v)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *store) MergeHLLs(c *cctx.Context, req []*models.HLLCount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range req {
+		s.hlls[v.Key] = v
+	}
+
+	return nil
+}
+
+func (s *store) DeleteHLLs(c *cctx.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.hlls, key)
+	}
+
+	return nil
+}
+
+func TestCountEventsBackfill(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+
+	id := randomPubkey()
+	st := &store{hlls: make(map[string]*models.HLLCount)}
+	for i := 0; i < 10; i++ {
+		st.pubkeys = append(st.pubkeys, randomPubkey())
+	}
+	s := &service{eventstore: st}
+	filters := &models.Filters{{Kinds: []int{7}, Tags: models.TagMap{"#e": []string{id}}}}
+
+	// ยังไม่มี register ใช้การนับจริง และคำนวณ register เบื้องหลัง
+	res, err := s.CountEvents(&cctx.Context{}, filters)
+	assert.NoError(t, err)
+	assert.False(t, res.Approximate)
+	assert.Equal(t, int64(10), res.Count)
+
+	assert.Eventually(t, func() bool {
+		res, err = s.CountEvents(&cctx.Context{}, filters)
+		return err == nil && res.Approximate
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(10), res.Count)
+
+	// event ที่นับอยู่ถูกลบ register ต้องถูกลบเพื่อคำนวณใหม่
+	err = s.InvalidateEvents(&cctx.Context{}, []*models.Event{{Kind: 7, Tags: models.Tags{{"e", id}}}})
+	assert.NoError(t, err)
+	assert.Empty(t, st.hlls)
+}
+
+func TestBackfillStale(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+
+	id := randomPubkey()
+	release := make(chan struct{})
+	st := &blockingStore{store: store{hlls: make(map[string]*models.HLLCount)}, release: release}
+	st.pubkeys = []string{randomPubkey()}
+	s := &service{eventstore: st}
+	filters := &models.Filters{{Kinds: []int{3}, Tags: models.TagMap{"#p": []string{id}}}}
+
+	// event ถูกลบระหว่างคำนวณ register ผลที่คำนวณได้ต้องไม่ถูกบันทึก
+	_, err := s.CountEvents(&cctx.Context{}, filters)
+	assert.NoError(t, err)
+	err = s.InvalidateEvents(&cctx.Context{}, []*models.Event{{Kind: 3, Tags: models.Tags{{"p", id}}}})
+	assert.NoError(t, err)
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		_, running := backfilling.Load(HLLKey(3, "p", id))
+		return !running
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, st.hlls)
+}
+
+// blockingStore store ที่รอให้ปล่อยก่อนคืน pubkey
+type blockingStore struct {
+	store
+	release chan struct{}
+}
+
+func (s *blockingStore) FindPubkeys(c *cctx.Context, req *eventstore.Request) ([]string, error) {
+	<-s.release
+	return s.pubkeys, nil
+}
@@ -0,0 +1,103 @@
+package nip62
+
+import (
+	"errors"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+)
+
+const (
+	// KindVanish kind ของคำขอลบข้อมูลทั้งหมด
+	KindVanish = 62
+
+	// AllRelays ค่า relay tag ที่หมายถึงทุกรีเลย์
+	AllRelays = "ALL_RELAYS"
+)
+
+// Service service interface
+type Service interface {
+	IsTarget(evt *models.Event, relayURL string) bool
+	Vanish(c *cctx.Context, evt *models.Event) error
+	IsVanished(c *cctx.Context, evt *models.Event) (bool, error)
+}
+
+type service struct {
+	config     *config.Configs
+	eventstore eventstore.Service
+	nip45      nip45.Service
+}
+
+func NewService() Service {
+	return &service{
+		config:     config.CF,
+		eventstore: eventstore.NewService(),
+		nip45:      nip45.NewService(),
+	}
+}
+
+// IsTarget check relay tag ระบุรีเลย์นี้ หรือ ALL_RELAYS
+func (s *service) IsTarget(evt *models.Event, relayURL string) bool {
+	for _, v := range *evt.Tags.FindAll("relay") {
+		if v.Value() == AllRelays || utils.IsSameRelayURL(v.Value(), relayURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Vanish ลบ event ทั้งหมดของ pubkey จนถึง created_at ของคำขอ
+// คำขอจะถูกเก็บไว้เพื่อส่งต่อให้รีเลย์อื่น
+func (s *service) Vanish(c *cctx.Context, evt *models.Event) error {
+	if generic.IsEmpty(evt) {
+		return errors.New("invalid: event not found")
+	}
+
+	v := &models.Vanish{
+		Pubkey:  evt.Pubkey,
+		EventID: evt.ID,
+		Until:   evt.CreatedAt,
+	}
+
+	// บันทึกก่อนลบ เพื่อไม่ให้ event เก่าถูกส่งเข้ามาระหว่างลบ
+	err := s.eventstore.InsertVanish(c, v)
+	if err != nil {
+		logger.Log.Errorf("insert vanish error: %s", err)
+		return errors.New("error: could not connect to the database")
+	}
+
+	// ให้ COUNT คำนวณ register ใหม่โดยไม่นับ event ที่จะถูกลบ
+	err = s.nip45.InvalidatePubkey(c, v.Pubkey, v.Until)
+	if err != nil {
+		logger.Log.Errorf("invalidate hll error: %s", err)
+	}
+
+	err = s.eventstore.DeleteEventsVanish(c, v)
+	if err != nil {
+		logger.Log.Errorf("delete events vanish error: %s", err)
+		return errors.New("error: could not connect to the database")
+	}
+
+	return nil
+}
+
+// IsVanished check pubkey ขอลบข้อมูลหลังจาก event นี้ถูกสร้าง
+func (s *service) IsVanished(c *cctx.Context, evt *models.Event) (bool, error) {
+	if evt.Kind == KindVanish {
+		return false, nil
+	}
+
+	fetch, err := s.eventstore.FindVanish(c, evt.Pubkey)
+	if err != nil {
+		return false, err
+	}
+
+	return fetch.ID > 0 && evt.CreatedAt <= fetch.Until, nil
+}
@@ -0,0 +1,26 @@
+package nip62
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/models"
+)
+
+func TestIsTarget(t *testing.T) {
+	s := NewService()
+	relayURL := "wss://relay.example.com/"
+
+	evt := &models.Event{Kind: KindVanish, Tags: models.Tags{{"relay", "ws://Relay.Example.com"}}}
+	assert.True(t, s.IsTarget(evt, relayURL))
+
+	evt = &models.Event{Kind: KindVanish, Tags: models.Tags{{"relay", AllRelays}}}
+	assert.True(t, s.IsTarget(evt, relayURL))
+
+	evt = &models.Event{Kind: KindVanish, Tags: models.Tags{{"relay", "wss://other.example.com"}}}
+	assert.False(t, s.IsTarget(evt, relayURL))
+
+	evt = &models.Event{Kind: KindVanish}
+	assert.False(t, s.IsTarget(evt, relayURL))
+}
@@ -0,0 +1,113 @@
+package nip98
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+const (
+	// KindHTTPAuth kind ของ event ที่ใช้ยืนยันตัวตน http
+	KindHTTPAuth = 27235
+
+	// ระยะเวลาที่ยอมรับ created_at ของ event ยืนยันตัวตน
+	authWindow = 60 * time.Second
+
+	authScheme = "Nostr "
+)
+
+// Service service interface
+type Service interface {
+	ValidateAuthHeader(c *cctx.Context, header, url, method string, body []byte) (string, error)
+}
+
+type service struct {
+	config *config.Configs
+}
+
+func NewService() Service {
+	return &service{
+		config: config.CF,
+	}
+}
+
+// ValidateAuthHeader validate header Authorization: Nostr <base64 event>
+// return pubkey ของผู้ส่ง
+func (s *service) ValidateAuthHeader(c *cctx.Context, header, url, method string, body []byte) (string, error) {
+	if !strings.HasPrefix(header, authScheme) {
+		return "", errors.New("auth-required: missing nostr authorization header")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(authScheme):]))
+	if err != nil {
+		return "", errors.New("invalid: authorization header is not base64")
+	}
+
+	evt := &models.Event{}
+	err = json.Unmarshal(raw, evt)
+	if err != nil {
+		return "", errors.New("invalid: failed to decode auth event")
+	}
+
+	err = s.validateAuthEvent(evt, url, method, body)
+	if err != nil {
+		return "", err
+	}
+
+	return evt.Pubkey, nil
+}
+
+func (s *service) validateAuthEvent(evt *models.Event, url, method string, body []byte) error {
+	if evt.Kind != KindHTTPAuth {
+		return errors.New("invalid: auth event must be kind 27235")
+	}
+
+	now := utils.Now()
+	createdAt := time.Unix(int64(evt.CreatedAt), 0)
+	if createdAt.Before(now.Add(-authWindow)) || createdAt.After(now.Add(authWindow)) {
+		return errors.New("invalid: auth event created_at is too far from the current time")
+	}
+
+	tag := evt.Tags.FindFirst("u")
+	if tag == nil || !utils.IsSameRelayURL(tag.Value(), url) {
+		return errors.New("invalid: url does not match")
+	}
+
+	tag = evt.Tags.FindFirst("method")
+	if tag == nil || !strings.EqualFold(tag.Value(), method) {
+		return errors.New("invalid: method does not match")
+	}
+
+	// ถ้ามี body ต้องมี payload เป็น sha256 ของ body
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		tag = evt.Tags.FindFirst("payload")
+		if tag == nil || !strings.EqualFold(tag.Value(), hex.EncodeToString(hash[:])) {
+			return errors.New("invalid: payload does not match")
+		}
+	}
+
+	if evt.GetID() != evt.ID {
+		return errors.New("invalid: event id is computed incorrectly")
+	}
+
+	ok, err := evt.VerifySignature()
+	if err != nil {
+		return errors.New("error: failed to verify signature")
+	}
+	if !ok {
+		return errors.New("invalid: signature is invalid")
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package nip98
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+func signEvent(t *testing.T, evt *models.Event) string {
+	sk, err := btcec.NewPrivateKey()
+	assert.NoError(t, err)
+
+	evt.Pubkey = hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey()))
+	evt.ID = evt.GetID()
+
+	hash := sha256.Sum256([]byte(evt.Serialize()))
+	sig, err := schnorr.Sign(sk, hash[:])
+	assert.NoError(t, err)
+	evt.Sig = hex.EncodeToString(sig.Serialize())
+
+	b, err := json.Marshal(evt)
+	assert.NoError(t, err)
+
+	return "Nostr " + base64.StdEncoding.EncodeToString(b)
+}
+
+func TestValidateAuthHeader(t *testing.T) {
+	s := NewService()
+	body := []byte(`{"method":"supportedmethods","params":[]}`)
+	payload := sha256.Sum256(body)
+
+	evt := &models.Event{
+		CreatedAt: models.Timestamp(utils.Now().Unix()),
+		Kind:      KindHTTPAuth,
+		Tags: models.Tags{
+			{"u", "https://relay.example.com"},
+			{"method", "POST"},
+			{"payload", hex.EncodeToString(payload[:])},
+		},
+	}
+	header := signEvent(t, evt)
+
+	pubkey, err := s.ValidateAuthHeader(cctx.New(), header, "wss://relay.example.com/", "POST", body)
+	assert.NoError(t, err)
+	assert.Equal(t, evt.Pubkey, pubkey)
+
+	_, err = s.ValidateAuthHeader(cctx.New(), header, "wss://relay.example.com", "GET", body)
+	assert.Error(t, err)
+
+	_, err = s.ValidateAuthHeader(cctx.New(), header, "wss://relay.example.com", "POST", []byte(`{}`))
+	assert.Error(t, err)
+
+	_, err = s.ValidateAuthHeader(cctx.New(), "", "wss://relay.example.com", "POST", body)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package policies
+
+import (
+	"sync/atomic"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// lists รายการแบนและ allow kinds ที่โหลดไว้ในหน่วยความจำ
+// ใช้ตรวจ event โดยไม่ต้องค้นฐานข้อมูลทุก event
+type lists struct {
+	pubkeys    map[string]struct{}
+	eventIDs   map[string]struct{}
+	kinds      map[int]struct{}
+	allowKinds map[int]struct{}
+}
+
+// รายการล่าสุด โหลดใหม่ตามรอบ cron และเมื่อรายการเปลี่ยน (nil คือยังไม่เคยโหลด)
+var snapshot atomic.Pointer[lists]
+
+// RefreshLists โหลดรายการแบนและ allow kinds ใหม่
+// ถ้าโหลดไม่สำเร็จใช้รายการเดิมต่อ
+func (s *service) RefreshLists(c *cctx.Context) error {
+	blacklists, err := s.eventstore.FindBlacklists(c, &models.Blacklist{})
+	if err != nil {
+		logger.Log.Errorf("load blacklists error: %s", err)
+		return err
+	}
+
+	allowKinds, err := s.eventstore.FindAllowKinds(c)
+	if err != nil {
+		logger.Log.Errorf("load allow kinds error: %s", err)
+		return err
+	}
+
+	res := &lists{
+		pubkeys:    make(map[string]struct{}),
+		eventIDs:   make(map[string]struct{}),
+		kinds:      make(map[int]struct{}),
+		allowKinds: make(map[int]struct{}, len(allowKinds)),
+	}
+	for _, v := range blacklists {
+		if v.Pubkey != "" {
+			res.pubkeys[v.Pubkey] = struct{}{}
+		}
+		if v.EventID != "" {
+			res.eventIDs[v.EventID] = struct{}{}
+		}
+		if v.Kind != nil {
+			res.kinds[*v.Kind] = struct{}{}
+		}
+	}
+	for _, v := range allowKinds {
+		res.allowKinds[v.Kind] = struct{}{}
+	}
+	snapshot.Store(res)
+
+	return nil
+}
+
+// lists รายการที่โหลดไว้ ถ้ายังไม่เคยโหลดจะโหลดก่อน
+// return รายการว่างเมื่อโหลดไม่สำเร็จ (รับ event ไว้ก่อน)
+func (s *service) lists(c *cctx.Context) *lists {
+	if v := snapshot.Load(); v != nil {
+		return v
+	}
+
+	if err := s.RefreshLists(c); err != nil {
+		return &lists{}
+	}
+
+	return snapshot.Load()
+}
@@ -11,24 +11,38 @@ import (
 	"github.com/saveblush/reraw-relay/models"
 	"github.com/saveblush/reraw-relay/pgk/eventstore"
 	"github.com/saveblush/reraw-relay/pgk/nips/nip13"
+	"github.com/saveblush/reraw-relay/pgk/wot"
 )
 
 // Service service interface
 type Service interface {
 	RejectEmptyHeaderUserAgent(r *http.Request) bool
 	RejectEmptyFilters(filter *models.Filter) (reject bool, msg string)
+	RejectTooManyFilters(filters *models.Filters) (reject bool, msg string)
+	RejectUnsupportedSearch(filters *models.Filters) (reject bool, msg string)
+	RejectEventTagsLength(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectEventContentLength(c *cctx.Context, evt *models.Event) (bool, string)
 	RejectEventWithCharacter(c *cctx.Context, evt *models.Event) (bool, string)
 	RejectValidateEvent(c *cctx.Context, evt *models.Event) (bool, string)
 	RejectValidatePow(c *cctx.Context, evt *models.Event) (bool, string)
 	RejectValidateTimeStamp(c *cctx.Context, evt *models.Event) (bool, string)
 	RejectEventFromPubkeyWithBlacklist(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectEventWithBlacklist(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectEventNotInAllowlist(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectEventKindNotAllowed(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectProtectedEvent(c *cctx.Context, evt *models.Event) (bool, string)
+	RejectEventNotInWebOfTrust(c *cctx.Context, evt *models.Event) (bool, string)
 	StoreBlacklistWithContent(c *cctx.Context, evt *models.Event) error
+	SeedAllowlists(c *cctx.Context) error
+	RefreshLists(c *cctx.Context) error
+	MinPowDifficulty() int
 }
 
 type service struct {
 	config     *config.Configs
 	eventstore eventstore.Service
 	nip13      nip13.Service
+	wot        wot.Service
 }
 
 func NewService() Service {
@@ -36,6 +50,7 @@ func NewService() Service {
 		config:     config.CF,
 		eventstore: eventstore.NewService(),
 		nip13:      nip13.NewService(),
+		wot:        wot.NewService(),
 	}
 }
 
@@ -81,3 +96,33 @@ func (s *service) RejectEmptyFilters(filter *models.Filter) (reject bool, msg st
 
 	return false, ""
 }
+
+// RejectUnsupportedSearch reject filter ที่ใช้ search extension ที่ไม่รองรับ (NIP-50)
+func (s *service) RejectUnsupportedSearch(filters *models.Filters) (reject bool, msg string) {
+	for _, filter := range *filters {
+		if filter.Search == "" {
+			continue
+		}
+
+		if unsupported := models.ParseSearch(filter.Search).Unsupported; len(unsupported) > 0 {
+			return true, fmt.Sprintf("unsupported: search extension %s is not supported", unsupported[0])
+		}
+	}
+
+	return false, ""
+}
+
+// RejectTooManyFilters reject filters more than max_filters
+func (s *service) RejectTooManyFilters(filters *models.Filters) (reject bool, msg string) {
+	max := s.config.Info.Limitation.MaxFilters
+	if max > 0 && len(*filters) > max {
+		return true, fmt.Sprintf("restricted: maximum of %d filters per subscription", max)
+	}
+
+	return false, ""
+}
+
+// MinPowDifficulty ความยากของ pow ขั้นต่ำปัจจุบัน
+func (s *service) MinPowDifficulty() int {
+	return s.nip13.MinDifficulty()
+}
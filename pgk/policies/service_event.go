@@ -3,9 +3,9 @@ package policies
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/saveblush/reraw-relay/core/cctx"
-	"github.com/saveblush/reraw-relay/core/generic"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
 )
@@ -46,6 +46,26 @@ func (s *service) RejectValidateTimeStamp(c *cctx.Context, evt *models.Event) (b
 	return false, ""
 }
 
+// RejectEventTagsLength reject event with tags more than max_event_tags
+func (s *service) RejectEventTagsLength(c *cctx.Context, evt *models.Event) (bool, string) {
+	max := s.config.Info.Limitation.MaxEventTags
+	if max > 0 && len(evt.Tags) > max {
+		return true, fmt.Sprintf("invalid: event must not have more than %d tags", max)
+	}
+
+	return false, ""
+}
+
+// RejectEventContentLength reject event with content longer than max_content_length
+func (s *service) RejectEventContentLength(c *cctx.Context, evt *models.Event) (bool, string) {
+	max := s.config.Info.Limitation.MaxContentLength
+	if max > 0 && utf8.RuneCountInString(evt.Content) > max {
+		return true, fmt.Sprintf("invalid: content must not be longer than %d characters", max)
+	}
+
+	return false, ""
+}
+
 // RejectEventWithCharacter reject event with character
 func (s *service) RejectEventWithCharacter(c *cctx.Context, evt *models.Event) (bool, string) {
 	if s.config.Blacklist.BlockWords.Enabled {
@@ -62,14 +82,110 @@ func (s *service) RejectEventWithCharacter(c *cctx.Context, evt *models.Event) (
 
 // RejectEventFromPubkeyWithBlacklist reject event from pubkey with blacklist
 func (s *service) RejectEventFromPubkeyWithBlacklist(c *cctx.Context, evt *models.Event) (bool, string) {
-	bots, err := s.eventstore.FindBlacklists(c, &models.Blacklist{Pubkey: evt.Pubkey})
+	if _, ok := s.lists(c).pubkeys[evt.Pubkey]; ok {
+		logger.Log.Warnf("found bot: %s", evt.Pubkey)
+		return true, fmt.Sprintf("blocked: %s", "hmm.")
+	}
+
+	return false, ""
+}
+
+// RejectEventWithBlacklist reject event id หรือ kind ที่ถูกแบน
+func (s *service) RejectEventWithBlacklist(c *cctx.Context, evt *models.Event) (bool, string) {
+	lists := s.lists(c)
+	if _, ok := lists.eventIDs[evt.ID]; ok {
+		return true, fmt.Sprintf("blocked: %s", "this event has been banned")
+	}
+
+	if _, ok := lists.kinds[evt.Kind]; ok {
+		return true, fmt.Sprintf("blocked: kind %d is not allowed", evt.Kind)
+	}
+
+	return false, ""
+}
+
+// RejectEventKindNotAllowed reject kind ที่ไม่อยู่ใน allow kinds
+// ถ้ายังไม่กำหนด allow kinds จะรับทุก kind
+func (s *service) RejectEventKindNotAllowed(c *cctx.Context, evt *models.Event) (bool, string) {
+	kinds := s.lists(c).allowKinds
+	if len(kinds) == 0 {
+		return false, ""
+	}
+
+	if _, ok := kinds[evt.Kind]; ok {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("blocked: kind %d is not allowed", evt.Kind)
+}
+
+// RejectEventNotInAllowlist reject event จาก pubkey ที่ไม่อยู่ใน allowlist (restricted writes)
+// กรณีเปิด inbox จะรับ event ที่ p-tag pubkey ใน allowlist ด้วย
+func (s *service) RejectEventNotInAllowlist(c *cctx.Context, evt *models.Event) (bool, string) {
+	if s.config.Info.Limitation == nil || !s.config.Info.Limitation.RestrictedWrites {
+		return false, ""
+	}
+
+	pubkeys := []string{evt.Pubkey}
+	if s.config.Allowlist.Inbox {
+		for _, v := range *evt.Tags.FindAll("p") {
+			if v.Value() != "" {
+				pubkeys = append(pubkeys, v.Value())
+			}
+		}
+	}
+
+	allowed, err := s.eventstore.IsAllowlisted(c, pubkeys)
 	if err != nil {
-		logger.Log.Warnf("reject find bot error: %s", err)
+		logger.Log.Errorf("find allowlist error: %s", err)
+		return true, fmt.Sprintf("error: %s", "could not connect to the database")
+	}
+	if !allowed {
+		return true, fmt.Sprintf("restricted: %s", "this relay only accepts events from allowlisted pubkeys")
 	}
 
-	if !generic.IsEmpty(bots) {
-		logger.Log.Warnf("found bot: %s", evt.Pubkey)
-		return true, fmt.Sprintf("blocked: %s", "hmm.")
+	return false, ""
+}
+
+// RejectEventNotInWebOfTrust reject event จาก pubkey ที่ไม่อยู่ใน web of trust
+// ระหว่างที่ยังคำนวณครั้งแรกไม่เสร็จจะรับทุก event
+func (s *service) RejectEventNotInWebOfTrust(c *cctx.Context, evt *models.Event) (bool, string) {
+	if !s.config.WebOfTrust.Enable || !s.wot.Ready() {
+		return false, ""
+	}
+
+	if !s.wot.IsTrusted(evt.Pubkey) {
+		return true, fmt.Sprintf("restricted: %s", "this relay only accepts events from its web of trust")
+	}
+
+	return false, ""
+}
+
+// SeedAllowlists เพิ่ม pubkey จาก config เข้า allowlist
+func (s *service) SeedAllowlists(c *cctx.Context) error {
+	for _, pubkey := range s.config.Allowlist.Pubkeys {
+		err := s.eventstore.InsertAllowlist(c, &models.Allowlist{Pubkey: pubkey, Reason: "config"})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RejectProtectedEvent reject protected event (NIP-70)
+// รับเฉพาะเมื่อการเชื่อมต่อยืนยันตัวตนเป็นผู้สร้าง event
+func (s *service) RejectProtectedEvent(c *cctx.Context, evt *models.Event) (bool, string) {
+	if !evt.Tags.IsProtected() {
+		return false, ""
+	}
+
+	if len(c.AuthedPubkeys()) == 0 {
+		return true, fmt.Sprintf("auth-required: %s", "this event may only be published by its author")
+	}
+
+	if !c.IsAuthedPubkey(evt.Pubkey) {
+		return true, fmt.Sprintf("restricted: %s", "this event may only be published by its author")
 	}
 
 	return false, ""
@@ -86,6 +202,11 @@ func (s *service) StoreBlacklistWithContent(c *cctx.Context, evt *models.Event)
 					logger.Log.Errorf("keep ban words error: %s", err)
 					return err
 				}
+
+				// แบนมีผลทันที
+				_ = s.RefreshLists(c)
+
+				return nil
 			}
 		}
 	}
@@ -5,10 +5,37 @@ import (
 
 	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
 )
 
+// listStore eventstore สำหรับทดสอบ คืนรายการแบนและ allow kinds ที่กำหนด นับจำนวนการค้น
+type listStore struct {
+	eventstore.Service
+	blacklists []*models.Blacklist
+	kinds      []int
+	finds      int
+}
+
+func (s *listStore) FindBlacklists(c *cctx.Context, req *models.Blacklist) ([]*models.Blacklist, error) {
+	s.finds++
+	return s.blacklists, nil
+}
+
+func (s *listStore) FindAllowKinds(c *cctx.Context) ([]*models.AllowKind, error) {
+	res := []*models.AllowKind{}
+	for _, kind := range s.kinds {
+		res = append(res, &models.AllowKind{Kind: kind})
+	}
+
+	return res, nil
+}
+
 func TestEventVerifying(t *testing.T) {
 	rawEvents := []string{
 		`
@@ -65,3 +92,76 @@ func TestEventVerifying(t *testing.T) {
 		assert.True(t, ok, "signature verification failed when it should have succeeded")
 	}
 }
+
+func TestRejectProtectedEvent(t *testing.T) {
+	s := &service{}
+	c := &cctx.Context{}
+	evt := &models.Event{Pubkey: "author", Tags: models.Tags{{"-"}}}
+
+	reject, _ := s.RejectProtectedEvent(c, &models.Event{Pubkey: "author"})
+	assert.False(t, reject)
+
+	reject, msg := s.RejectProtectedEvent(c, evt)
+	assert.True(t, reject)
+	assert.Contains(t, msg, "auth-required:")
+
+	reject, msg = s.RejectProtectedEvent(c.WithAuthedPubkeys([]string{"other"}), evt)
+	assert.True(t, reject)
+	assert.Contains(t, msg, "restricted:")
+
+	reject, _ = s.RejectProtectedEvent(c.WithAuthedPubkeys([]string{"other", "author"}), evt)
+	assert.False(t, reject)
+}
+
+func TestRejectEventNotInAllowlistDisabled(t *testing.T) {
+	cf := &config.Configs{}
+	cf.Info.Limitation = &config.InfoLimitation{}
+	s := &service{config: cf}
+
+	reject, _ := s.RejectEventNotInAllowlist(&cctx.Context{}, &models.Event{Pubkey: "anyone"})
+	assert.False(t, reject)
+}
+
+func TestRejectEventKindNotAllowed(t *testing.T) {
+	defer snapshot.Store(nil)
+	st := &listStore{}
+	s := &service{config: &config.Configs{}, eventstore: st}
+
+	// ยังไม่กำหนด allow kinds รับทุก kind
+	reject, _ := s.RejectEventKindNotAllowed(&cctx.Context{}, &models.Event{Kind: 7})
+	assert.False(t, reject)
+
+	st.kinds = []int{0, 1}
+	assert.NoError(t, s.RefreshLists(&cctx.Context{}))
+	reject, _ = s.RejectEventKindNotAllowed(&cctx.Context{}, &models.Event{Kind: 1})
+	assert.False(t, reject)
+
+	reject, msg := s.RejectEventKindNotAllowed(&cctx.Context{}, &models.Event{Kind: 7})
+	assert.True(t, reject)
+	assert.Contains(t, msg, "blocked:")
+}
+
+func TestRejectEventWithBlacklist(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+	defer snapshot.Store(nil)
+	kind := 4
+	st := &listStore{blacklists: []*models.Blacklist{{Pubkey: "bot"}, {EventID: "banned"}, {Kind: &kind}}}
+	s := &service{config: &config.Configs{}, eventstore: st}
+	c := &cctx.Context{}
+
+	reject, _ := s.RejectEventFromPubkeyWithBlacklist(c, &models.Event{Pubkey: "bot"})
+	assert.True(t, reject)
+	reject, _ = s.RejectEventWithBlacklist(c, &models.Event{ID: "banned", Kind: 1})
+	assert.True(t, reject)
+	reject, _ = s.RejectEventWithBlacklist(c, &models.Event{ID: "other", Kind: 4})
+	assert.True(t, reject)
+
+	// ใช้รายการที่โหลดไว้ ไม่ค้นฐานข้อมูลทุก event
+	for i := 0; i < 3; i++ {
+		reject, _ = s.RejectEventFromPubkeyWithBlacklist(c, &models.Event{Pubkey: "user"})
+		assert.False(t, reject)
+		reject, _ = s.RejectEventWithBlacklist(c, &models.Event{ID: "other", Kind: 1})
+		assert.False(t, reject)
+	}
+	assert.Equal(t, 1, st.finds)
+}
@@ -0,0 +1,153 @@
+package wot
+
+import (
+	"sync/atomic"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+const (
+	// KindContacts kind ของรายชื่อที่ติดตาม (NIP-02)
+	KindContacts = 3
+
+	// จำนวน pubkey ต่อการค้นหารายชื่อที่ติดตามหนึ่งครั้ง
+	batchSize = 500
+)
+
+// graph pubkey ที่อยู่ใน web of trust ใช้ร่วมกันทั้งรีเลย์
+var graph atomic.Pointer[map[string]struct{}]
+
+// Service service interface
+type Service interface {
+	Refresh(c *cctx.Context) error
+	IsTrusted(pubkey string) bool
+	Ready() bool
+	Size() int
+}
+
+type service struct {
+	config     *config.Configs
+	eventstore eventstore.Service
+}
+
+func NewService() Service {
+	return &service{
+		config:     config.CF,
+		eventstore: eventstore.NewService(),
+	}
+}
+
+// Refresh คำนวณ web of trust ใหม่จากรายชื่อที่ติดตาม (kind 3) ที่จัดเก็บไว้
+func (s *service) Refresh(c *cctx.Context) error {
+	trusted, err := s.compute(c)
+	if err != nil {
+		logger.Log.Errorf("compute web of trust error: %s", err)
+		return err
+	}
+
+	graph.Store(&trusted)
+	logger.Log.Infof("web of trust refreshed: %d pubkeys", len(trusted))
+
+	return nil
+}
+
+// IsTrusted check pubkey อยู่ใน web of trust
+func (s *service) IsTrusted(pubkey string) bool {
+	trusted := graph.Load()
+	if trusted == nil {
+		return false
+	}
+
+	_, ok := (*trusted)[pubkey]
+	return ok
+}
+
+// Ready check คำนวณ web of trust แล้วอย่างน้อยหนึ่งครั้ง
+func (s *service) Ready() bool {
+	return graph.Load() != nil
+}
+
+// Size จำนวน pubkey ใน web of trust
+func (s *service) Size() int {
+	trusted := graph.Load()
+	if trusted == nil {
+		return 0
+	}
+
+	return len(*trusted)
+}
+
+// compute ไล่ตามรายชื่อที่ติดตามจาก root ไม่เกิน depth hop
+// pubkey ที่ห่างจาก root มากกว่า 1 hop ต้องถูกติดตามโดย pubkey ใน hop ก่อนหน้าอย่างน้อย min_followers
+func (s *service) compute(c *cctx.Context) (map[string]struct{}, error) {
+	cf := s.config.WebOfTrust
+
+	trusted := make(map[string]struct{}, len(cf.Roots))
+	frontier := make([]string, 0, len(cf.Roots))
+	for _, pubkey := range cf.Roots {
+		if _, ok := trusted[pubkey]; ok {
+			continue
+		}
+		trusted[pubkey] = struct{}{}
+		frontier = append(frontier, pubkey)
+	}
+
+	for hop := 1; hop <= cf.Depth && len(frontier) > 0; hop++ {
+		followers, err := s.followers(c, frontier, trusted)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []string
+		for pubkey, count := range followers {
+			if hop > 1 && count < cf.MinFollowers {
+				continue
+			}
+			trusted[pubkey] = struct{}{}
+			next = append(next, pubkey)
+		}
+		frontier = next
+	}
+
+	return trusted, nil
+}
+
+// followers จำนวนผู้ติดตามจาก pubkeys ของแต่ละ pubkey ที่ยังไม่อยู่ใน trusted
+func (s *service) followers(c *cctx.Context, pubkeys []string, trusted map[string]struct{}) (map[string]int, error) {
+	res := make(map[string]int)
+	for start := 0; start < len(pubkeys); start += batchSize {
+		end := min(start+batchSize, len(pubkeys))
+
+		fetch, err := s.eventstore.FindAll(c, &eventstore.Request{
+			NostrFilter: &models.Filter{Kinds: []int{KindContacts}, Authors: pubkeys[start:end]},
+			NoLimit:     true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, evt := range fetch {
+			seen := make(map[string]struct{})
+			for _, tag := range *evt.Tags.FindAll("p") {
+				pubkey := tag.Value()
+				if len(pubkey) != 64 {
+					continue
+				}
+				if _, ok := trusted[pubkey]; ok {
+					continue
+				}
+				if _, ok := seen[pubkey]; ok {
+					continue
+				}
+				seen[pubkey] = struct{}{}
+				res[pubkey]++
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,65 @@
+package wot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+// store eventstore สำหรับทดสอบ คืนรายชื่อที่ติดตามจาก contacts
+type store struct {
+	eventstore.Service
+	contacts map[string][]string
+}
+
+func (s store) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	var res []*models.Event
+	for _, author := range req.NostrFilter.Authors {
+		evt := &models.Event{Pubkey: author, Kind: KindContacts}
+		for _, pubkey := range s.contacts[author] {
+			evt.Tags = append(evt.Tags, models.Tag{"p", pubkey})
+		}
+		res = append(res, evt)
+	}
+
+	return res, nil
+}
+
+func pk(s string) string {
+	return strings.Repeat(s, 64)
+}
+
+func TestCompute(t *testing.T) {
+	cf := &config.Configs{}
+	cf.WebOfTrust.Roots = []string{pk("a")}
+	cf.WebOfTrust.Depth = 2
+	cf.WebOfTrust.MinFollowers = 2
+
+	s := &service{config: cf, eventstore: store{contacts: map[string][]string{
+		pk("a"): {pk("b"), pk("c")},
+		pk("b"): {pk("d"), pk("e"), pk("a")},
+		pk("c"): {pk("d")},
+		pk("d"): {pk("f")},
+	}}}
+
+	trusted, err := s.compute(&cctx.Context{})
+	assert.NoError(t, err)
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		assert.Contains(t, trusted, pk(v))
+	}
+	// e มีผู้ติดตามจาก hop ก่อนหน้าไม่ถึง 2, f เกิน depth
+	assert.NotContains(t, trusted, pk("e"))
+	assert.NotContains(t, trusted, pk("f"))
+
+	graph.Store(&trusted)
+	assert.True(t, s.Ready())
+	assert.True(t, s.IsTrusted(pk("d")))
+	assert.Equal(t, 4, s.Size())
+}
@@ -0,0 +1,104 @@
+package relay
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/saveblush/reraw-relay/core/config"
+)
+
+// isAdmin check token ของ admin จาก header Authorization: Bearer <token>
+func (rl *Relay) isAdmin(r *http.Request) bool {
+	token := config.CF.App.Admin.Token
+	if token == "" {
+		return false
+	}
+
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+}
+
+// handleAdminBlockIPs จัดการ block ip
+//
+//	GET    /admin/blockips                                รายการ ip ที่ถูก block
+//	POST   /admin/blockips?ip=&duration=&reason=          block ip (ไม่ระบุ duration จะคำนวณตามจำนวนครั้ง)
+//	DELETE /admin/blockips?ip=                            ยกเลิก block ip
+func (rl *Relay) handleAdminBlockIPs(w http.ResponseWriter, r *http.Request) {
+	if config.CF.App.Admin.Token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !rl.isAdmin(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ip := r.URL.Query().Get("ip")
+	switch r.Method {
+	case http.MethodGet:
+		rl.responseJSON(w, http.StatusOK, rl.BlockedIPs())
+
+	case http.MethodPost:
+		if ip == "" {
+			http.Error(w, "ip is required", http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if v := r.URL.Query().Get("duration"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			duration = d
+		}
+
+		res, err := rl.BlockIP(ip, r.URL.Query().Get("reason"), duration)
+		// block ในหน่วยความจำแล้ว ปิดการเชื่อมต่อเดิมของ ip นี้
+		rl.disconnectIP(ip)
+		if err != nil {
+			http.Error(w, errConnectDatabase.Error(), http.StatusInternalServerError)
+			return
+		}
+		rl.responseJSON(w, http.StatusOK, res)
+
+	case http.MethodDelete:
+		if ip == "" {
+			http.Error(w, "ip is required", http.StatusBadRequest)
+			return
+		}
+
+		err := rl.UnblockIP(ip)
+		if err != nil {
+			http.Error(w, errConnectDatabase.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// responseJSON response json
+func (rl *Relay) responseJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,27 @@
+package relay
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saveblush/reraw-relay/core/config"
+)
+
+func TestIsAdmin(t *testing.T) {
+	config.CF.App.Admin.Token = "secret"
+	rl := &Relay{}
+
+	for auth, expected := range map[string]bool{
+		"Bearer secret": true,
+		"secret":        false,
+		"Bearer other":  false,
+		"Basic secret":  false,
+		"":              false,
+	} {
+		r := httptest.NewRequest("GET", "/admin/blockips", nil)
+		r.Header.Set("Authorization", auth)
+		assert.Equal(t, expected, rl.isAdmin(r), auth)
+	}
+}
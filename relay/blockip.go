@@ -0,0 +1,165 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// ระยะเวลาที่ limiter ของ IP ไม่มีการใช้งานก่อนถูกลบ
+const limiterIdleTimeout = 10 * time.Minute
+
+// loadBlockIPs โหลดรายการ block ip ที่ยังไม่หมดอายุจาก database
+func (rl *Relay) loadBlockIPs() {
+	fetch, err := rl.eventstore.FindBlockIPs(rl.cctx)
+	if err != nil {
+		logger.Log.Errorf("load block ips error: %s", err)
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for _, v := range fetch {
+		rl.limiterBlockIPs[v.IP] = v
+	}
+}
+
+// isBlockedIP check ip ถูก block และยังไม่หมดอายุ
+func (rl *Relay) isBlockedIP(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	v, exists := rl.limiterBlockIPs[ip]
+	if !exists {
+		return false
+	}
+
+	if !v.Active() {
+		delete(rl.limiterBlockIPs, ip)
+		return false
+	}
+
+	return true
+}
+
+// BlockIP block ip
+// ถ้า duration เป็น 0 จะคำนวณจากจำนวนครั้งที่เคยโดน block (เพิ่มเป็น 2 เท่าทุกครั้ง)
+// block ในหน่วยความจำเสมอ แม้บันทึกลง database ไม่สำเร็จ
+func (rl *Relay) BlockIP(ip, reason string, duration time.Duration) (*models.BlockIP, error) {
+	count := 1
+	previous, err := rl.eventstore.FindBlockIP(rl.cctx, ip)
+	if err != nil {
+		logger.Log.Errorf("find block ip error: %s", err)
+	} else {
+		count = previous.Count + 1
+	}
+
+	if duration <= 0 {
+		duration = rl.blockIPDuration(count)
+	}
+
+	v := &models.BlockIP{
+		IP:        ip,
+		Reason:    reason,
+		Count:     count,
+		ExpiresAt: utils.Now().Add(duration),
+	}
+
+	rl.mu.Lock()
+	rl.limiterBlockIPs[ip] = v
+	rl.mu.Unlock()
+
+	logger.Log.Warnf("block ip: %s until %s (%d times)", ip, v.ExpiresAt.Format(time.RFC3339), count)
+
+	err = rl.eventstore.InsertBlockIP(rl.cctx, v)
+	if err != nil {
+		logger.Log.Errorf("insert block ip error: %s", err)
+		return v, err
+	}
+
+	return v, nil
+}
+
+// disconnectIP ปิดการเชื่อมต่อ client ทั้งหมดของ ip
+func (rl *Relay) disconnectIP(ip string) {
+	rl.mu.Lock()
+	clients := make([]*Client, 0)
+	for client := range rl.clients {
+		if client.IP() == ip {
+			clients = append(clients, client)
+		}
+	}
+	rl.mu.Unlock()
+
+	for _, client := range clients {
+		logger.Log.Infof("blocked ip %s disconnecting...", ip)
+		client.close()
+	}
+}
+
+// UnblockIP unblock ip
+func (rl *Relay) UnblockIP(ip string) error {
+	err := rl.eventstore.DeleteBlockIP(rl.cctx, ip)
+	if err != nil {
+		logger.Log.Errorf("delete block ip error: %s", err)
+		return err
+	}
+
+	rl.mu.Lock()
+	delete(rl.limiterBlockIPs, ip)
+	rl.mu.Unlock()
+
+	return nil
+}
+
+// BlockedIPs รายการ ip ที่ถูก block อยู่
+func (rl *Relay) BlockedIPs() []*models.BlockIP {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	res := make([]*models.BlockIP, 0, len(rl.limiterBlockIPs))
+	for _, v := range rl.limiterBlockIPs {
+		if v.Active() {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// blockIPDuration ระยะเวลา block ตามจำนวนครั้ง
+func (rl *Relay) blockIPDuration(count int) time.Duration {
+	base := config.CF.App.RateLimit.BlockIPDuration
+	max := config.CF.App.RateLimit.BlockIPMaxDuration
+
+	duration := base
+	for i := 1; i < count && duration < max; i++ {
+		duration *= 2
+	}
+
+	if duration > max {
+		duration = max
+	}
+
+	return duration
+}
+
+// cleanupLimiter ลบ limiter ที่ไม่ได้ใช้งานและ block ip ที่หมดอายุ
+func (rl *Relay) cleanupLimiter() {
+	if rl.limiter != nil {
+		rl.limiter.Cleanup(limiterIdleTimeout)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for ip, v := range rl.limiterBlockIPs {
+		if !v.Active() {
+			delete(rl.limiterBlockIPs, ip)
+		}
+	}
+}
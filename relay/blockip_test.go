@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+// blockIPStore eventstore สำหรับทดสอบ เก็บ block ip ในหน่วยความจำ
+type blockIPStore struct {
+	eventstore.Service
+	err  error
+	data map[string]*models.BlockIP
+}
+
+func (s *blockIPStore) FindBlockIP(c *cctx.Context, ip string) (*models.BlockIP, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if v, ok := s.data[ip]; ok {
+		return v, nil
+	}
+
+	return &models.BlockIP{}, nil
+}
+
+func (s *blockIPStore) InsertBlockIP(c *cctx.Context, v *models.BlockIP) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.data[v.IP] = v
+
+	return nil
+}
+
+func newBlockIPRelay(store *blockIPStore) *Relay {
+	logger.Log = zap.NewNop().Sugar()
+	config.CF.App.RateLimit.BlockIPDuration = time.Minute
+	config.CF.App.RateLimit.BlockIPMaxDuration = 10 * time.Minute
+
+	return &Relay{
+		limiterBlockIPs: make(map[string]*models.BlockIP),
+		cctx:            cctx.New(),
+		eventstore:      store,
+	}
+}
+
+func TestBlockIPEscalation(t *testing.T) {
+	rl := newBlockIPRelay(&blockIPStore{data: make(map[string]*models.BlockIP)})
+
+	assert.Equal(t, time.Minute, rl.blockIPDuration(1))
+	assert.Equal(t, 2*time.Minute, rl.blockIPDuration(2))
+	assert.Equal(t, 8*time.Minute, rl.blockIPDuration(4))
+	assert.Equal(t, 10*time.Minute, rl.blockIPDuration(5))
+	assert.Equal(t, 10*time.Minute, rl.blockIPDuration(100))
+
+	for i := 1; i <= 3; i++ {
+		v, err := rl.BlockIP("1.1.1.1", "test", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, i, v.Count)
+	}
+	assert.True(t, rl.isBlockedIP("1.1.1.1"))
+}
+
+func TestBlockIPDatabaseError(t *testing.T) {
+	rl := newBlockIPRelay(&blockIPStore{err: errors.New("database down")})
+
+	// database ใช้งานไม่ได้ ยังต้อง block ในหน่วยความจำด้วยระยะเวลาเริ่มต้น
+	v, err := rl.BlockIP("1.1.1.1", "test", 0)
+	assert.Error(t, err)
+	assert.Equal(t, 1, v.Count)
+	assert.WithinDuration(t, utils.Now().Add(time.Minute), v.ExpiresAt, time.Second)
+	assert.True(t, rl.isBlockedIP("1.1.1.1"))
+}
+
+func TestBlockIPExpiry(t *testing.T) {
+	rl := newBlockIPRelay(&blockIPStore{data: make(map[string]*models.BlockIP)})
+	rl.limiterBlockIPs["1.1.1.1"] = &models.BlockIP{IP: "1.1.1.1", ExpiresAt: utils.Now().Add(-time.Second)}
+	rl.limiterBlockIPs["2.2.2.2"] = &models.BlockIP{IP: "2.2.2.2", ExpiresAt: utils.Now().Add(-time.Second)}
+	rl.limiterBlockIPs["3.3.3.3"] = &models.BlockIP{IP: "3.3.3.3", ExpiresAt: utils.Now().Add(time.Minute)}
+
+	assert.False(t, rl.isBlockedIP("1.1.1.1"))
+	assert.NotContains(t, rl.limiterBlockIPs, "1.1.1.1")
+
+	rl.cleanupLimiter()
+	assert.NotContains(t, rl.limiterBlockIPs, "2.2.2.2")
+	assert.True(t, rl.isBlockedIP("3.3.3.3"))
+}
@@ -1,22 +1,42 @@
 package relay
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/gorilla/websocket"
 
 	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/metrics"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 )
 
 type Client struct {
-	relay *Relay
-	conn  *websocket.Conn
+	relay  *Relay
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
 
-	ip          string
-	userAgent   string
-	connectedAt time.Time
+	inbox     chan []byte
+	outbound  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	subMu  sync.RWMutex
+	authMu sync.RWMutex
+
+	ip            string
+	userAgent     string
+	connectedAt   time.Time
+	subscriptions map[string]*subscription
+
+	relayURL      string
+	challenge     string
+	authedPubkeys []string
 }
 
 func (client *Client) IP() string {
@@ -27,15 +47,124 @@ func (client *Client) UserAgent() string {
 	return client.userAgent
 }
 
+// AuthedPubkeys pubkey ที่ยืนยันตัวตนแล้ว (NIP-42)
+func (client *Client) AuthedPubkeys() []string {
+	client.authMu.RLock()
+	defer client.authMu.RUnlock()
+
+	return slices.Clone(client.authedPubkeys)
+}
+
+// IsAuthed check client ยืนยันตัวตนแล้ว
+func (client *Client) IsAuthed() bool {
+	client.authMu.RLock()
+	defer client.authMu.RUnlock()
+
+	return len(client.authedPubkeys) > 0
+}
+
+func (client *Client) authenticate(pubkey string) {
+	client.authMu.Lock()
+	defer client.authMu.Unlock()
+
+	if !slices.Contains(client.authedPubkeys, pubkey) {
+		client.authedPubkeys = append(client.authedPubkeys, pubkey)
+	}
+}
+
 func (client *Client) Info() string {
 	return fmt.Sprintf("IP: %s, Connected At: %s", client.ip, client.connectedAt.Format(time.RFC3339))
 }
 
+// send ส่งข้อความเข้าคิวของ client
+// ถ้าคิวเต็ม (client อ่านไม่ทัน) จะตัดการเชื่อมต่อ
+func (client *Client) send(msg interface{}) error {
+	b, err := json.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-client.done:
+		return errClientClosed
+	default:
+	}
+
+	select {
+	case client.outbound <- b:
+		return nil
+	case <-client.done:
+		return errClientClosed
+	default:
+		logger.Log.Warnf("slow consumer %s disconnecting...", client.IP())
+		client.close()
+		return errSlowConsumer
+	}
+}
+
+// close ปิดการเชื่อมต่อ client
+func (client *Client) close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+// flush รอจนคิวข้อความว่างหรือครบเวลา ctx
+func (client *Client) flush(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for len(client.outbound) > 0 {
+		select {
+		case <-client.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writer เขียนข้อความจากคิวไปยัง client และส่ง ping ตามรอบ
+func (client *Client) writer() {
+	ticker := time.NewTicker(client.relay.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.close()
+	}()
+
+	for {
+		select {
+		case b := <-client.outbound:
+			client.conn.SetWriteDeadline(time.Now().Add(client.relay.WriteWait))
+			err := client.conn.WriteMessage(websocket.TextMessage, b)
+			if err != nil {
+				logger.Log.Warnf("write to %s error: %s", client.IP(), err)
+				return
+			}
+
+		case <-ticker.C:
+			err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(client.relay.WriteWait))
+			if err != nil {
+				logger.Log.Warnf("ping %s error: %s", client.IP(), err)
+				return
+			}
+
+		case <-client.done:
+			return
+		}
+	}
+}
+
 // reader อ่านข้อความจาก client
 func (client *Client) reader() {
 	defer func() {
+		client.cancel()
+		close(client.inbox)
+		client.closeSubscriptions()
 		client.relay.unregister <- client
-		client.conn.Close()
+		client.close()
 	}()
 
 	// config การเชื่อมต่อ websocket
@@ -47,8 +176,8 @@ func (client *Client) reader() {
 	client.conn.SetReadDeadline(time.Now().Add(client.relay.PongWait))
 	client.conn.SetPongHandler(func(string) error { client.conn.SetReadDeadline(time.Now().Add(client.relay.PongWait)); return nil })
 
-	rt := newHandleEvent()
-	rt.client = client
+	// ประมวลผลข้อความจาก client
+	go client.processor()
 
 	for {
 		mt, msg, err := client.conn.ReadMessage()
@@ -70,32 +199,95 @@ func (client *Client) reader() {
 			break
 		}
 
-		if mt == websocket.PingMessage {
-			client.conn.WriteMessage(websocket.PongMessage, nil)
-			continue
-		}
-
 		if client.relay.limiter != nil {
 			rate := client.relay.limiter.GetLimiter(client.IP())
 			if !rate.Allow() {
 				logger.Log.Infof("limiter %s disconnecting...", client.IP())
+				metrics.RateLimitDisconnects.Inc()
 				if config.CF.App.RateLimit.BlockIPEnable {
-					client.relay.mu.Lock()
-					client.relay.limiterBlockIPs[client.IP()] = true
-					client.relay.mu.Unlock()
+					_, _ = client.relay.BlockIP(client.IP(), "rate limit exceeded", 0)
 				}
 				break
 			}
 		}
 
-		go func(msg []byte) {
-			logger.Log.Infof("[received] %s %s", client.IP(), msg)
+		// ยกเลิก query ของ subscription ทันทีไม่ต้องรอคิว (ลบ subscription ตอนประมวลผล CLOSE)
+		if cmd, subID := peekMessage(msg); cmd == "CLOSE" {
+			client.cancelSubscription(subID)
+		}
 
-			err = rt.handleEvent(msg)
-			if err != nil {
-				logger.Log.Errorf("handle event error: %s", err)
+		select {
+		case client.inbox <- msg:
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// processor ประมวลผลข้อความจาก client ตามลำดับที่ได้รับ
+func (client *Client) processor() {
+	rt := newHandleEvent()
+	rt.client = client
+
+	client.processMessages(rt)
+}
+
+// processMessages อ่านข้อความจากคิวและประมวลผลจนกว่าการเชื่อมต่อจะปิด
+// ข้อความที่ค้างในคิวหลังปิดการเชื่อมต่อจะถูกทิ้ง
+// กรณี mode parallel จะประมวลผล REQ พร้อมกันได้ไม่เกิน REQ_PARALLELISM
+func (client *Client) processMessages(rt *service) {
+	cf := config.CF.App.Processing
+	parallel := cf.Mode == config.ProcessingParallel && cf.ReqParallelism > 1
+	var reqSlots chan struct{}
+	if parallel {
+		reqSlots = make(chan struct{}, cf.ReqParallelism)
+	}
+
+	for {
+		var msg []byte
+		var ok bool
+		select {
+		case <-client.ctx.Done():
+			return
+		case <-client.done:
+			return
+		case msg, ok = <-client.inbox:
+			if !ok || client.ctx.Err() != nil {
 				return
 			}
-		}(msg)
+		}
+
+		logger.Log.Infof("[received] %s %s", client.IP(), msg)
+
+		// จำกัดจำนวน worker ทั้งรีเลย์
+		err := client.relay.acquireWorker()
+		if err != nil {
+			cmd, id := peekMessage(msg)
+			_ = rt.responseRejected(cmd, id, err.Error())
+			continue
+		}
+
+		if parallel {
+			if cmd, _ := peekMessage(msg); cmd == "REQ" {
+				select {
+				case reqSlots <- struct{}{}:
+				case <-client.ctx.Done():
+					client.relay.releaseWorker()
+					return
+				}
+
+				go func(msg []byte) {
+					defer func() {
+						<-reqSlots
+						client.relay.releaseWorker()
+					}()
+					rt.process(msg)
+				}(msg)
+				continue
+			}
+		}
+
+		rt.process(msg)
+		client.relay.releaseWorker()
 	}
 }
@@ -0,0 +1,172 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+// reqStore eventstore สำหรับทดสอบ บันทึกลำดับ query และจำนวนที่ทำงานพร้อมกัน
+type reqStore struct {
+	eventstore.Service
+	release chan struct{}
+
+	mu      sync.Mutex
+	kinds   []int
+	current atomic.Int32
+	max     atomic.Int32
+}
+
+func (s *reqStore) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	s.mu.Lock()
+	s.kinds = append(s.kinds, req.NostrFilter.Kinds...)
+	s.mu.Unlock()
+
+	n := s.current.Add(1)
+	defer s.current.Add(-1)
+	for {
+		m := s.max.Load()
+		if n <= m || s.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	if s.release != nil {
+		<-s.release
+	}
+
+	return nil, nil
+}
+
+func newTestClient(store *reqStore, mode config.ProcessingMode, parallelism int) (*Client, *service) {
+	logger.Log = zap.NewNop().Sugar()
+	config.CF.Info.Limitation = &config.InfoLimitation{}
+	config.CF.App.Processing.Mode = mode
+	config.CF.App.Processing.ReqParallelism = parallelism
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		relay:         &Relay{},
+		ctx:           ctx,
+		cancel:        cancel,
+		inbox:         make(chan []byte, 16),
+		outbound:      make(chan []byte, 64),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*subscription),
+	}
+
+	rt := &service{client: client, config: config.CF, cctx: &cctx.Context{}, eventstore: store}
+
+	return client, rt
+}
+
+func reqMessage(i int) []byte {
+	return []byte(fmt.Sprintf(`["REQ","sub%d",{"kinds":[%d]}]`, i, i))
+}
+
+// responses อ่านข้อความที่ส่งถึง client จนได้ครบ n ข้อความ
+func responses(t *testing.T, client *Client, n int) [][]interface{} {
+	var res [][]interface{}
+	for len(res) < n {
+		select {
+		case b := <-client.outbound:
+			var msg []interface{}
+			assert.NoError(t, json.Unmarshal(b, &msg))
+			res = append(res, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d responses, got %d", n, len(res))
+		}
+	}
+
+	return res
+}
+
+func TestProcessMessagesOrdered(t *testing.T) {
+	store := &reqStore{}
+	client, rt := newTestClient(store, config.ProcessingOrdered, 0)
+
+	for i := 1; i <= 5; i++ {
+		client.inbox <- reqMessage(i)
+	}
+	close(client.inbox)
+
+	client.processMessages(rt)
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, store.kinds)
+	for i, msg := range responses(t, client, 5) {
+		assert.Equal(t, []interface{}{"EOSE", fmt.Sprintf("sub%d", i+1)}, msg)
+	}
+}
+
+func TestProcessMessagesOverflow(t *testing.T) {
+	store := &reqStore{}
+	client, rt := newTestClient(store, config.ProcessingOrdered, 0)
+
+	// worker ทั้งรีเลย์เต็ม
+	client.relay.workers = make(chan struct{}, 1)
+	client.relay.workers <- struct{}{}
+
+	client.inbox <- reqMessage(1)
+	close(client.inbox)
+
+	client.processMessages(rt)
+
+	assert.Empty(t, store.kinds)
+	assert.Equal(t, []interface{}{"CLOSED", "sub1", errRateLimited.Error()}, responses(t, client, 1)[0])
+}
+
+func TestProcessMessagesParallelLimit(t *testing.T) {
+	store := &reqStore{release: make(chan struct{})}
+	client, rt := newTestClient(store, config.ProcessingParallel, 2)
+
+	for i := 1; i <= 4; i++ {
+		client.inbox <- reqMessage(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.processMessages(rt)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return store.current.Load() == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), store.current.Load())
+
+	close(store.release)
+	responses(t, client, 4)
+	assert.Equal(t, int32(2), store.max.Load())
+
+	close(client.inbox)
+	<-done
+	client.relay.inflight.Wait()
+}
+
+func TestProcessMessagesClosed(t *testing.T) {
+	store := &reqStore{}
+	client, rt := newTestClient(store, config.ProcessingOrdered, 0)
+
+	// ข้อความที่ค้างในคิวหลังปิดการเชื่อมต่อต้องถูกทิ้ง
+	for i := 1; i <= 3; i++ {
+		client.inbox <- reqMessage(i)
+	}
+	client.cancel()
+
+	client.processMessages(rt)
+
+	assert.Empty(t, store.kinds)
+	assert.Empty(t, client.outbound)
+}
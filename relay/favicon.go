@@ -0,0 +1,247 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+)
+
+//go:embed assets/favicon.png
+var defaultFavicon []byte
+
+const (
+	faviconCacheFile = "favicon"
+
+	// http.DetectContentType ไม่รู้จัก svg (ได้ text/xml หรือ text/plain)
+	contentTypeSVG = "image/svg+xml"
+)
+
+type favicon struct {
+	data        []byte
+	contentType string
+	etag        string
+}
+
+// newFavicon new favicon ตรวจสอบว่าเป็นไฟล์รูปภาพ
+func newFavicon(data []byte) (*favicon, error) {
+	contentType := http.DetectContentType(data)
+	if isSVG(contentType, data) {
+		contentType = contentTypeSVG
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("favicon is not an image: %s", contentType)
+	}
+
+	hash := sha256.Sum256(data)
+
+	return &favicon{
+		data:        data,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(hash[:8]) + `"`,
+	}, nil
+}
+
+// isSVG check data เป็น svg จาก tag <svg ในส่วนต้นของไฟล์ (หลัง xml prolog, comment หรือ doctype)
+func isSVG(contentType string, data []byte) bool {
+	if !strings.HasPrefix(contentType, "text/xml") && !strings.HasPrefix(contentType, "text/plain") {
+		return false
+	}
+
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// loadFavicon load favicon
+// ลำดับ: ไฟล์ในเครื่อง > ไฟล์ cache > icon url (โหลดเบื้องหลังและรีเฟรชตามรอบ) > favicon เริ่มต้น
+func (rl *Relay) loadFavicon() {
+	f, _ := newFavicon(defaultFavicon)
+	rl.favicon.Store(f)
+
+	cf := config.CF.App.Favicon
+	if cf.Path != "" {
+		data, err := readFileLimit(cf.Path, cf.MaxSize)
+		if err == nil {
+			f, err = newFavicon(data)
+		}
+		if err != nil {
+			logger.Log.Errorf("load favicon %s error: %s", cf.Path, err)
+			return
+		}
+		rl.favicon.Store(f)
+		return
+	}
+
+	if cf.CacheDir != "" {
+		data, err := readFileLimit(filepath.Join(cf.CacheDir, faviconCacheFile), cf.MaxSize)
+		if err == nil {
+			if f, err := newFavicon(data); err == nil {
+				rl.favicon.Store(f)
+			}
+		}
+	}
+
+	if config.CF.Info.Icon != "" {
+		go rl.refreshFavicon()
+	}
+}
+
+// refreshFavicon โหลด icon url และรีเฟรชตามรอบ
+func (rl *Relay) refreshFavicon() {
+	err := rl.fetchFavicon(config.CF.Info.Icon)
+	if err != nil {
+		logger.Log.Warnf("fetch favicon error: %s", err)
+	}
+
+	interval := config.CF.App.Favicon.RefreshInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.ctx.Done():
+			return
+		case <-ticker.C:
+			err := rl.fetchFavicon(config.CF.Info.Icon)
+			if err != nil {
+				logger.Log.Warnf("fetch favicon error: %s", err)
+			}
+		}
+	}
+}
+
+// fetchFavicon โหลด icon จาก url และบันทึกลง cache
+func (rl *Relay) fetchFavicon(iconURL string) error {
+	cf := config.CF.App.Favicon
+	client := &http.Client{Timeout: cf.Timeout}
+	resp, err := client.Get(iconURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := readLimit(resp.Body, cf.MaxSize)
+	if err != nil {
+		return err
+	}
+
+	f, err := newFavicon(data)
+	if err != nil {
+		return err
+	}
+	rl.favicon.Store(f)
+
+	if cf.CacheDir != "" {
+		err := writeFileAtomic(filepath.Join(cf.CacheDir, faviconCacheFile), data)
+		if err != nil {
+			logger.Log.Warnf("write favicon cache error: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// handleFavicon handle favicon
+func (rl *Relay) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	f := rl.favicon.Load()
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", f.etag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if r.Header.Get("If-None-Match") == f.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	if f.contentType == contentTypeSVG {
+		// svg เปิดตรงได้เหมือนหน้าเว็บ ไม่ให้รัน script
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	}
+	_, _ = w.Write(f.data)
+}
+
+// iconURL url ของ icon สำหรับ NIP-11
+// ถ้าไม่ได้กำหนด icon จะใช้ /favicon.ico ของรีเลย์
+func (rl *Relay) iconURL() string {
+	if config.CF.Info.Icon != "" {
+		return config.CF.Info.Icon
+	}
+
+	u, err := url.Parse(rl.ServiceURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	scheme := "https"
+	if u.Scheme == "ws" || u.Scheme == "http" {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/favicon.ico", scheme, u.Host)
+}
+
+func readLimit(r io.Reader, max int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > max {
+		return nil, errors.New("favicon is too large")
+	}
+
+	return data, nil
+}
+
+func readFileLimit(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readLimit(f, max)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
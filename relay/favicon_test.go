@@ -0,0 +1,28 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFavicon(t *testing.T) {
+	f, err := newFavicon(defaultFavicon)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", f.contentType)
+
+	// svg ทั้งแบบมีและไม่มี xml prolog
+	for _, data := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"></svg>`,
+		`<?xml version="1.0" encoding="UTF-8"?><!-- icon --><svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+	} {
+		f, err = newFavicon([]byte(data))
+		assert.NoError(t, err)
+		assert.Equal(t, contentTypeSVG, f.contentType)
+	}
+
+	_, err = newFavicon([]byte("not an image"))
+	assert.Error(t, err)
+	_, err = newFavicon([]byte(`<?xml version="1.0"?><rss></rss>`))
+	assert.Error(t, err)
+}
@@ -3,7 +3,6 @@ package relay
 import (
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -11,6 +10,7 @@ import (
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
 	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/metrics"
 	"github.com/saveblush/reraw-relay/core/utils"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
@@ -18,7 +18,9 @@ import (
 	"github.com/saveblush/reraw-relay/pgk/nips/nip09"
 	"github.com/saveblush/reraw-relay/pgk/nips/nip13"
 	"github.com/saveblush/reraw-relay/pgk/nips/nip40"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip42"
 	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip62"
 )
 
 type service struct {
@@ -26,14 +28,15 @@ type service struct {
 
 	config *config.Configs
 	cctx   *cctx.Context
-	mu     sync.Mutex
 
 	eventstore eventstore.Service
 
 	nip09 nip09.Service
 	nip13 nip13.Service
 	nip40 nip40.Service
+	nip42 nip42.Service
 	nip45 nip45.Service
+	nip62 nip62.Service
 }
 
 // newHandleEvent new handle event
@@ -46,7 +49,17 @@ func newHandleEvent() *service {
 		nip09:      nip09.NewService(),
 		nip13:      nip13.NewService(),
 		nip40:      nip40.NewService(),
+		nip42:      nip42.NewService(),
 		nip45:      nip45.NewService(),
+		nip62:      nip62.NewService(),
+	}
+}
+
+// process handle message and log error
+func (s *service) process(msg []byte) {
+	err := s.handleEvent(msg)
+	if err != nil {
+		logger.Log.Errorf("handle event error: %s", err)
 	}
 }
 
@@ -70,6 +83,7 @@ func (s *service) handleEvent(msg []byte) error {
 	}
 
 	json.Unmarshal(*req[0], &cmd)
+	metrics.ObserveMessage(cmd)
 
 	switch cmd {
 	case "EVENT":
@@ -100,6 +114,13 @@ func (s *service) handleEvent(msg []byte) error {
 			return err
 		}
 
+	case "AUTH":
+		err := s.onAuth(req)
+		if err != nil {
+			logger.Log.Errorf("[auth] error: %s", err)
+			return err
+		}
+
 	default:
 		_ = s.responseError(errUnknownCommand.Error())
 		return errUnknownCommand
@@ -114,14 +135,74 @@ func (s *service) onEvent(req []*json.RawMessage) error {
 		return err
 	}
 
+	if s.isAuthRequired() {
+		_ = s.responseOK(evt.ID, false, errAuthRequired.Error())
+		return errAuthRequired
+	}
+
 	// check reject
+	c := s.cctx.WithAuthedPubkeys(s.client.AuthedPubkeys())
 	for _, rejectFunc := range s.client.relay.rejectEvent {
-		if reject, msg := rejectFunc(s.cctx, evt); reject {
+		if reject, msg := rejectFunc(c, evt); reject {
 			_ = s.responseOK(evt.ID, false, msg)
 			return errors.New(msg)
 		}
 	}
 
+	// check expiration (NIP-40)
+	_, err = s.nip40.Expiration(s.cctx, evt)
+	if err != nil {
+		_ = s.responseOK(evt.ID, false, err.Error())
+		return err
+	}
+
+	// เหตุการณ์ชั่วคราว (ephemeral) จะไม่จัดเก็บโดยรีเลย์
+	// ส่งต่อให้ subscription ที่เปิดอยู่เท่านั้น
+	if s.isEphemeralKind(evt.Kind) {
+		_ = s.responseOK(evt.ID, true, "")
+		s.client.relay.broadcast(evt)
+		return nil
+	}
+
+	// คำขอลบข้อมูลต้องระบุรีเลย์นี้ (NIP-62)
+	if evt.Kind == nip62.KindVanish && !s.nip62.IsTarget(evt, s.client.relayURL) {
+		_ = s.responseOK(evt.ID, false, errVanishTarget.Error())
+		return errVanishTarget
+	}
+
+	// check vanished (NIP-62)
+	vanished, err := s.nip62.IsVanished(s.cctx, evt)
+	if err != nil {
+		logger.Log.Errorf("find vanish error: %s", err)
+		_ = s.responseOK(evt.ID, false, errConnectDatabase.Error())
+		return errConnectDatabase
+	}
+	if vanished {
+		_ = s.responseOK(evt.ID, false, errVanishedEvent.Error())
+		return errVanishedEvent
+	}
+
+	// check deleted (NIP-09)
+	deleted, err := s.nip09.IsDeleted(s.cctx, evt)
+	if err != nil {
+		logger.Log.Errorf("find deletion error: %s", err)
+		_ = s.responseOK(evt.ID, false, errConnectDatabase.Error())
+		return errConnectDatabase
+	}
+	if deleted {
+		_ = s.responseOK(evt.ID, false, errDeletedEvent.Error())
+		return errDeletedEvent
+	}
+
+	// check คำขอลบก่อนจัดเก็บ (NIP-09)
+	if evt.Kind == nip09.KindDeletion {
+		err = s.nip09.Validate(evt)
+		if err != nil {
+			_ = s.responseOK(evt.ID, false, err.Error())
+			return err
+		}
+	}
+
 	// clear older
 	err = s.clearEventOlder(evt)
 	if err != nil {
@@ -159,13 +240,26 @@ func (s *service) onEvent(req []*json.RawMessage) error {
 		return errConnectDatabase
 	}
 
+	// อัปเดต register สำหรับ COUNT (NIP-45)
+	err = s.nip45.StoreEvent(s.cctx, evt)
+	if err != nil {
+		logger.Log.Errorf("store hll error: %s", err)
+	}
+
 	// handlers kind
 	switch evt.Kind {
-	case 5:
-		// soft delete
+	case nip09.KindDeletion:
+		// soft delete คำขอถูกจัดเก็บแล้ว จึงตอบ OK และบันทึก error ไว้
 		err = s.nip09.CancelEvent(s.cctx, evt)
 		if err != nil {
 			logger.Log.Errorf("soft delete error: %s", err)
+		}
+
+	case nip62.KindVanish:
+		// ลบข้อมูลทั้งหมดของผู้ใช้
+		err = s.nip62.Vanish(s.cctx, evt)
+		if err != nil {
+			logger.Log.Errorf("vanish error: %s", err)
 			_ = s.responseOK(evt.ID, false, err.Error())
 			return err
 		}
@@ -173,12 +267,16 @@ func (s *service) onEvent(req []*json.RawMessage) error {
 
 	_ = s.responseOK(evt.ID, true, "")
 
+	// ส่ง event ให้ subscription ที่เปิดอยู่
+	s.client.relay.broadcast(evt)
+
 	return nil
 }
 
 func (s *service) onReq(req []*json.RawMessage) error {
 	subID, err := s.subID(req)
 	if err != nil {
+		_ = s.responseSubIDError(subID, err)
 		return err
 	}
 
@@ -187,27 +285,59 @@ func (s *service) onReq(req []*json.RawMessage) error {
 		return err
 	}
 
-	for idx, filter := range *filters {
-		// check reject
+	if s.isAuthRequired() {
+		_ = s.responseClosed(subID, errAuthRequired.Error())
+		return errAuthRequired
+	}
+
+	// check reject
+	if reject, msg := s.rejectReq(filters); reject {
+		_ = s.responseClosed(subID, msg)
+		return errors.New(msg)
+	}
+
+	for _, filter := range *filters {
 		for _, rejectFunc := range s.client.relay.rejectFilter {
 			if reject, msg := rejectFunc(&filter); reject {
 				_ = s.responseClosed(subID, msg)
 				return errors.New(msg)
 			}
 		}
+	}
 
-		events, err := s.eventstore.FindAll(s.cctx, &eventstore.Request{NostrFilter: &filter})
+	// เก็บ subscription ไว้ส่ง event ใหม่แบบ realtime
+	ctx, err := s.client.setSubscription(subID, *filters)
+	if err != nil {
+		_ = s.responseClosed(subID, err.Error())
+		return err
+	}
+	c := s.cctx.WithContext(ctx)
+
+	for idx, filter := range *filters {
+		events, err := s.eventstore.FindAll(c, &eventstore.Request{NostrFilter: &filter})
 		if err != nil {
+			// subscription ถูกยกเลิกระหว่าง query (CLOSE หรือ REQ ซ้ำ id เดิม)
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			logger.Log.Errorf("find filter [index: %d] error: %s", idx, err)
+			s.client.removeSubscription(subID)
 			_ = s.responseClosed(subID, errConnectDatabase.Error())
 			return err
 		}
 
 		for _, event := range events {
+			if ctx.Err() != nil {
+				return nil
+			}
 			_ = s.responseEvent(subID, event)
 		}
 	}
 
+	if ctx.Err() != nil {
+		return nil
+	}
 	_ = s.responseEose(subID)
 
 	return nil
@@ -225,12 +355,16 @@ func (s *service) onClose(req []*json.RawMessage) error {
 		return errSubIDNotFound
 	}
 
+	// ยกเลิก subscription และ query ที่ค้างอยู่
+	s.client.removeSubscription(subID)
+
 	return nil
 }
 
 func (s *service) onCount(req []*json.RawMessage) error {
 	subID, err := s.subID(req)
 	if err != nil {
+		_ = s.responseSubIDError(subID, err)
 		return err
 	}
 
@@ -239,22 +373,48 @@ func (s *service) onCount(req []*json.RawMessage) error {
 		return err
 	}
 
-	var total int64
-	for idx, filter := range *filters {
-		count, err := s.nip45.CountEvent(s.cctx, &filter)
-		if err != nil {
-			logger.Log.Errorf("count filter [index: %d] error: %s", idx, err)
-			_ = s.responseClosed(subID, errConnectDatabase.Error())
-			return err
-		}
-		total += *count
+	if s.isAuthRequired() {
+		_ = s.responseClosed(subID, errAuthRequired.Error())
+		return errAuthRequired
+	}
+
+	// check reject
+	if reject, msg := s.rejectReq(filters); reject {
+		_ = s.responseClosed(subID, msg)
+		return errors.New(msg)
 	}
 
-	err = s.responseCount(subID, &total)
+	count, err := s.nip45.CountEvents(s.cctx, filters)
 	if err != nil {
+		logger.Log.Errorf("count error: %s", err)
+		_ = s.responseClosed(subID, errConnectDatabase.Error())
 		return err
 	}
 
+	err = s.responseCount(subID, count)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) onAuth(req []*json.RawMessage) error {
+	evt, err := s.parseEvent(req)
+	if err != nil {
+		_ = s.responseError(err.Error())
+		return err
+	}
+
+	ok, err := s.nip42.ValidateAuthEvent(s.cctx, evt, s.client.challenge, s.client.relayURL)
+	if !ok {
+		_ = s.responseOK(evt.ID, false, err.Error())
+		return err
+	}
+
+	s.client.authenticate(evt.Pubkey)
+	_ = s.responseOK(evt.ID, true, "")
+
 	return nil
 }
 
@@ -265,10 +425,7 @@ func (s *service) clearEventOlder(evt *models.Event) error {
 
 	filterEvent := &models.Filter{}
 	var isDeleteOlder bool
-	if s.isEphemeralKind(evt.Kind) {
-		// เหตุการณ์เกิดขึ้นชั่วคราว จะไม่จัดเก็บโดยรีเลย์
-		return nil
-	} else if s.isReplaceableKind(evt.Kind) {
+	if s.isReplaceableKind(evt.Kind) {
 		// event ที่แก้ไขข้อมูลได้
 		filterEvent = &models.Filter{Authors: []string{evt.Pubkey}, Kinds: []int{evt.Kind}}
 		isDeleteOlder = true
@@ -296,15 +453,25 @@ func (s *service) clearEventOlder(evt *models.Event) error {
 		}
 
 		// ลบ event ที่เก่ากว่า
+		var deleted []*models.Event
 		for _, previous := range fetch {
 			if s.isOlder(previous, evt) {
-				err := s.eventstore.Delete(s.cctx, &models.Event{ID: previous.ID})
+				err = s.eventstore.Delete(s.cctx, &models.Event{ID: previous.ID})
 				if err != nil {
 					logger.Log.Errorf("delete older error: %s", err)
-					return err
+					break
 				}
+				deleted = append(deleted, previous)
 			}
 		}
+
+		// ลบ register ที่ event เดิมนับไว้ เช่น ผู้ติดตามที่ถูก unfollow (kind 3)
+		if err := s.nip45.InvalidateEvents(s.cctx, deleted); err != nil {
+			logger.Log.Errorf("invalidate hll error: %s", err)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+)
+
+// replaceStore eventstore สำหรับทดสอบ มี event เดิมและบันทึก id ที่ถูกลบ
+type replaceStore struct {
+	eventstore.Service
+	events  []*models.Event
+	deleted []string
+}
+
+func (s *replaceStore) FindAll(c *cctx.Context, req *eventstore.Request) ([]*models.Event, error) {
+	return s.events, nil
+}
+
+func (s *replaceStore) Delete(c *cctx.Context, req *models.Event) error {
+	s.deleted = append(s.deleted, req.ID)
+	return nil
+}
+
+// hllStore nip45 สำหรับทดสอบ บันทึก event ที่ถูกลบ register
+type hllStore struct {
+	nip45.Service
+	invalidated []*models.Event
+}
+
+func (s *hllStore) InvalidateEvents(c *cctx.Context, events []*models.Event) error {
+	s.invalidated = append(s.invalidated, events...)
+	return nil
+}
+
+func TestClearEventOlderInvalidatesHLL(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+
+	previous := &models.Event{ID: "old", Pubkey: "alice", Kind: 3, CreatedAt: 1, Tags: models.Tags{{"p", "bob"}}}
+	st := &replaceStore{events: []*models.Event{previous}}
+	hll := &hllStore{}
+	rt := &service{config: config.CF, cctx: &cctx.Context{}, eventstore: st, nip45: hll}
+
+	// alice เลิกติดตาม bob register ผู้ติดตามของ bob ต้องถูกลบเพื่อคำนวณใหม่
+	err := rt.clearEventOlder(&models.Event{ID: "new", Pubkey: "alice", Kind: 3, CreatedAt: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old"}, st.deleted)
+	assert.Equal(t, []*models.Event{previous}, hll.invalidated)
+
+	// event ที่ใหม่กว่าไม่ถูกลบ
+	st.deleted, hll.invalidated = nil, nil
+	err = rt.clearEventOlder(&models.Event{ID: "older", Pubkey: "alice", Kind: 3, CreatedAt: 0})
+	assert.NoError(t, err)
+	assert.Empty(t, st.deleted)
+	assert.Empty(t, hll.invalidated)
+}
+
+func TestReqSubIDTooLong(t *testing.T) {
+	client, rt := newTestClient(&reqStore{}, config.ProcessingOrdered, 0)
+	config.CF.Info.Limitation.MaxSubidLength = 4
+
+	client.inbox <- []byte(`["REQ","toolong",{"kinds":[1]}]`)
+	client.inbox <- []byte(`["COUNT",1,{"kinds":[1]}]`)
+	close(client.inbox)
+
+	client.processMessages(rt)
+
+	res := responses(t, client, 2)
+	assert.Equal(t, []interface{}{"CLOSED", "toolong", "invalid: subscription ID must be between 1 and 4 characters"}, res[0])
+	assert.Equal(t, []interface{}{"NOTICE", errGetSubID.Error()}, res[1])
+}
@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/sql"
+)
+
+// เวลาสูงสุดของการตรวจสอบความพร้อม
+const readyCheckTimeout = 3 * time.Second
+
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// AddReadyCheck เพิ่มการตรวจสอบความพร้อมของ dependency ให้ /readyz
+func (rl *Relay) AddReadyCheck(name string, check func(ctx context.Context) error) {
+	rl.readyChecks = append(rl.readyChecks, readyCheck{name: name, check: check})
+}
+
+// handleHealthz process ยังทำงานอยู่
+func (rl *Relay) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	rl.responseJSON(w, http.StatusOK, &healthResponse{Status: "ok"})
+}
+
+// handleReadyz รีเลย์พร้อมรับ request
+func (rl *Relay) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+
+	checks := append([]readyCheck{
+		{name: "database", check: rl.checkDatabase},
+		{name: "migration", check: rl.checkMigration},
+		{name: "draining", check: rl.checkDraining},
+	}, rl.readyChecks...)
+
+	res := &healthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+	for _, v := range checks {
+		err := v.check(ctx)
+		if err != nil {
+			res.Status = "unavailable"
+			res.Checks[v.name] = err.Error()
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[v.name] = "ok"
+	}
+
+	rl.responseJSON(w, status, res)
+}
+
+func (rl *Relay) checkDatabase(ctx context.Context) error {
+	db, err := rl.cctx.WithContext(ctx).GetDatabase().DB()
+	if err != nil {
+		return err
+	}
+
+	return db.PingContext(ctx)
+}
+
+func (rl *Relay) checkMigration(ctx context.Context) error {
+	if !sql.IsMigrated() {
+		return errors.New("migrations not applied")
+	}
+
+	return nil
+}
+
+func (rl *Relay) checkDraining(ctx context.Context) error {
+	if rl.stopping.Load() || rl.isDraining() {
+		return errShuttingDown
+	}
+
+	return nil
+}
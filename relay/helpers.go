@@ -1,6 +1,8 @@
 package relay
 
 import (
+	"fmt"
+
 	"github.com/goccy/go-json"
 
 	"github.com/saveblush/reraw-relay/core/generic"
@@ -25,6 +27,24 @@ func (s *service) isOlder(previous, next *models.Event) bool {
 		(previous.CreatedAt == next.CreatedAt && previous.ID > next.ID)
 }
 
+// isAuthRequired check รีเลย์บังคับยืนยันตัวตนแต่ client ยังไม่ได้ยืนยัน
+func (s *service) isAuthRequired() bool {
+	return s.config.Info.Limitation.AuthRequired && !s.client.IsAuthed()
+}
+
+// rejectReq check reject filters ของ REQ/COUNT
+func (s *service) rejectReq(filters *models.Filters) (bool, string) {
+	for _, rejectFunc := range s.client.relay.rejectFilters {
+		if reject, msg := rejectFunc(filters); reject {
+			return true, msg
+		}
+	}
+
+	return false, ""
+}
+
+// subID อ่าน subscription id และ check ความยาว
+// return id ที่อ่านได้คู่กับ error เพื่อแจ้ง CLOSED ได้
 func (s *service) subID(req []*json.RawMessage) (string, error) {
 	var id string
 	err := json.Unmarshal(*req[1], &id)
@@ -32,13 +52,45 @@ func (s *service) subID(req []*json.RawMessage) (string, error) {
 		return "", errGetSubID
 	}
 
-	if len(id) > 64 {
-		return "", errrInvalidESubID
+	// ความยาวตาม max_subid_length ถ้าไม่กำหนดใช้ 64
+	max := 64
+	if s.config.Info.Limitation.MaxSubidLength > 0 {
+		max = s.config.Info.Limitation.MaxSubidLength
+	}
+	if len(id) > max {
+		return id, fmt.Errorf("invalid: subscription ID must be between 1 and %d characters", max)
 	}
 
 	return id, nil
 }
 
+// peekMessage อ่าน command และ id (event id หรือ subscription id) ของข้อความ
+func peekMessage(msg []byte) (string, string) {
+	var req []*json.RawMessage
+	err := json.Unmarshal(msg, &req)
+	if err != nil || len(req) < 2 {
+		return "", ""
+	}
+
+	var cmd string
+	_ = json.Unmarshal(*req[0], &cmd)
+
+	var id string
+	switch cmd {
+	case "EVENT":
+		var evt struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(*req[len(req)-1], &evt)
+		id = evt.ID
+
+	default:
+		_ = json.Unmarshal(*req[1], &id)
+	}
+
+	return cmd, id
+}
+
 // parseFilters parse filters
 func (s *service) parseFilters(req []*json.RawMessage) (*models.Filters, error) {
 	if len(req) < 3 {
@@ -0,0 +1,426 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/goccy/go-json"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/generic"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+const (
+	// contentTypeManagement content type ของ management api (NIP-86)
+	contentTypeManagement = "application/nostr+json+rpc"
+
+	// ขนาด body สูงสุดของ management api
+	managementMaxBodySize = 64 * 1024
+)
+
+// methods ที่รองรับ
+var managementMethods = []string{
+	"supportedmethods",
+	"banpubkey",
+	"unbanpubkey",
+	"listbannedpubkeys",
+	"allowpubkey",
+	"unallowpubkey",
+	"listallowedpubkeys",
+	"banevent",
+	"listbannedevents",
+	"allowkind",
+	"disallowkind",
+	"listallowedkinds",
+	"blockip",
+	"unblockip",
+	"listblockedips",
+	"changerelayname",
+	"changerelaydescription",
+}
+
+var (
+	errManagementParams = errors.New("invalid params")
+	errManagementMethod = errors.New("method not supported")
+)
+
+type managementRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type managementResponse struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type managementPubkey struct {
+	Pubkey string `json:"pubkey"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type managementEvent struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type managementIP struct {
+	IP     string `json:"ip"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// isManagementRequest check content type ของ management api
+func isManagementRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == contentTypeManagement
+}
+
+// handleManagement management api (NIP-86)
+// ยืนยันตัวตนด้วย NIP-98 และต้องเป็น pubkey ที่กำหนดใน config
+func (rl *Relay) handleManagement(w http.ResponseWriter, r *http.Request) {
+	admins := config.CF.App.Admin.Pubkeys
+	if len(admins) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, managementMaxBodySize))
+	if err != nil {
+		rl.responseJSON(w, http.StatusBadRequest, &managementResponse{Error: "failed to read request"})
+		return
+	}
+
+	pubkey, err := rl.nip98.ValidateAuthHeader(rl.cctx, r.Header.Get("Authorization"), rl.relayURL(r), r.Method, body)
+	if err != nil {
+		rl.responseJSON(w, http.StatusUnauthorized, &managementResponse{Error: err.Error()})
+		return
+	}
+	if !slices.Contains(admins, pubkey) {
+		rl.responseJSON(w, http.StatusUnauthorized, &managementResponse{Error: "restricted: pubkey is not an admin"})
+		return
+	}
+
+	req := &managementRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		rl.responseJSON(w, http.StatusBadRequest, &managementResponse{Error: "invalid request"})
+		return
+	}
+
+	logger.Log.Infof("[management] %s %s", pubkey, req.Method)
+
+	res, err := rl.manage(req)
+	if err != nil {
+		rl.responseJSON(w, http.StatusOK, &managementResponse{Error: err.Error()})
+		return
+	}
+
+	rl.responseJSON(w, http.StatusOK, &managementResponse{Result: res})
+}
+
+// manage ประมวลผล method
+func (rl *Relay) manage(req *managementRequest) (interface{}, error) {
+	switch req.Method {
+	case "supportedmethods":
+		return managementMethods, nil
+
+	case "banpubkey":
+		pubkey, reason, err := paramsHexWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.eventstore.InsertBlacklist(rl.cctx, &models.Blacklist{Pubkey: pubkey, Reason: reason})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshLists()
+
+		// ลบ event ของ pubkey ที่ถูกแบน และ register ที่ event เหล่านั้นนับไว้
+		deleted, err := rl.eventstore.ClearEventsWithPubkey(rl.cctx, pubkey)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		err = rl.nip45.InvalidateEvents(rl.cctx, deleted)
+		if err != nil {
+			logger.Log.Errorf("invalidate hll error: %s", err)
+		}
+
+		return true, nil
+
+	case "unbanpubkey":
+		pubkey, _, err := paramsHexWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.eventstore.DeleteBlacklist(rl.cctx, &models.Blacklist{Pubkey: pubkey})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshLists()
+
+		return true, nil
+
+	case "allowpubkey":
+		pubkey, reason, err := paramsHexWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.eventstore.InsertAllowlist(rl.cctx, &models.Allowlist{Pubkey: pubkey, Reason: reason})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshAllowlist(pubkey)
+
+		return true, nil
+
+	case "unallowpubkey":
+		pubkey, _, err := paramsHexWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.eventstore.DeleteAllowlist(rl.cctx, pubkey)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshAllowlist(pubkey)
+
+		return true, nil
+
+	case "listallowedpubkeys":
+		fetch, err := rl.eventstore.FindAllowlists(rl.cctx)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		res := []*managementPubkey{}
+		for _, v := range fetch {
+			res = append(res, &managementPubkey{Pubkey: v.Pubkey, Reason: v.Reason})
+		}
+
+		return res, nil
+
+	case "listbannedpubkeys":
+		fetch, err := rl.eventstore.FindBlacklists(rl.cctx, &models.Blacklist{})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		res := []*managementPubkey{}
+		for _, v := range fetch {
+			if v.Pubkey != "" {
+				res = append(res, &managementPubkey{Pubkey: v.Pubkey, Reason: v.Reason})
+			}
+		}
+
+		return res, nil
+
+	case "banevent":
+		id, reason, err := paramsHexWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.eventstore.InsertBlacklist(rl.cctx, &models.Blacklist{EventID: id, Reason: reason})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshLists()
+
+		evt, err := rl.eventstore.FindByID(rl.cctx, id)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		err = rl.eventstore.SoftDelete(rl.cctx, &models.Event{ID: id})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		if !generic.IsEmpty(evt) {
+			err = rl.nip45.InvalidateEvents(rl.cctx, []*models.Event{evt})
+			if err != nil {
+				logger.Log.Errorf("invalidate hll error: %s", err)
+			}
+		}
+
+		return true, nil
+
+	case "listbannedevents":
+		fetch, err := rl.eventstore.FindBlacklists(rl.cctx, &models.Blacklist{})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		res := []*managementEvent{}
+		for _, v := range fetch {
+			if v.EventID != "" {
+				res = append(res, &managementEvent{ID: v.EventID, Reason: v.Reason})
+			}
+		}
+
+		return res, nil
+
+	case "allowkind":
+		var kind int
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &kind) != nil {
+			return nil, errManagementParams
+		}
+
+		// เพิ่มเข้า allow kinds และยกเลิกแบน kind เดิม
+		err := rl.eventstore.InsertAllowKind(rl.cctx, &models.AllowKind{Kind: kind})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		err = rl.eventstore.DeleteBlacklist(rl.cctx, &models.Blacklist{Kind: &kind})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshLists()
+
+		return true, nil
+
+	case "disallowkind":
+		var kind int
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &kind) != nil {
+			return nil, errManagementParams
+		}
+
+		// ลบออกจาก allow kinds และแบน kind เพื่อไม่ให้กลับไปรับทุก kind เมื่อ allow kinds ว่าง
+		err := rl.eventstore.DeleteAllowKind(rl.cctx, kind)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		err = rl.eventstore.InsertBlacklist(rl.cctx, &models.Blacklist{Kind: &kind})
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+		rl.refreshLists()
+
+		return true, nil
+
+	case "listallowedkinds":
+		fetch, err := rl.eventstore.FindAllowKinds(rl.cctx)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		res := []int{}
+		for _, v := range fetch {
+			res = append(res, v.Kind)
+		}
+
+		return res, nil
+
+	case "blockip":
+		ip, reason, err := paramsWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = rl.BlockIP(ip, reason, config.CF.App.RateLimit.BlockIPMaxDuration)
+		rl.disconnectIP(ip)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		return true, nil
+
+	case "unblockip":
+		ip, _, err := paramsWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rl.UnblockIP(ip)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		return true, nil
+
+	case "listblockedips":
+		res := []*managementIP{}
+		for _, v := range rl.BlockedIPs() {
+			res = append(res, &managementIP{IP: v.IP, Reason: v.Reason})
+		}
+
+		return res, nil
+
+	case "changerelayname", "changerelaydescription":
+		value, _, err := paramsWithReason(req.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		key := settingRelayName
+		if req.Method == "changerelaydescription" {
+			key = settingRelayDescription
+		}
+
+		err = rl.setSetting(key, value)
+		if err != nil {
+			return nil, errConnectDatabase
+		}
+
+		return true, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errManagementMethod, req.Method)
+}
+
+// refreshLists โหลดรายการแบนและ allow kinds ที่จำไว้ใหม่ให้มีผลทันที
+func (rl *Relay) refreshLists() {
+	err := rl.policies.RefreshLists(rl.cctx)
+	if err != nil {
+		logger.Log.Errorf("refresh policy lists error: %s", err)
+	}
+}
+
+// refreshAllowlist โหลด allowlist ที่จำไว้ใหม่ให้ pow มีผลทันที
+func (rl *Relay) refreshAllowlist(pubkey string) {
+	err := rl.nip13.RefreshPubkey(rl.cctx, pubkey)
+	if err != nil {
+		logger.Log.Errorf("refresh pow allowlist error: %s", err)
+	}
+}
+
+// paramsWithReason params ในรูปแบบ [value, reason?]
+func paramsWithReason(params []json.RawMessage) (value, reason string, err error) {
+	if len(params) < 1 || json.Unmarshal(params[0], &value) != nil || value == "" {
+		return "", "", errManagementParams
+	}
+
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &reason)
+	}
+
+	return value, reason, nil
+}
+
+// paramsHexWithReason params ในรูปแบบ [hex, reason?] สำหรับ pubkey และ event id
+func paramsHexWithReason(params []json.RawMessage) (value, reason string, err error) {
+	value, reason, err = paramsWithReason(params)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !utils.IsHex64(value) {
+		return "", "", fmt.Errorf("%w: expected 64-character lowercase hex", errManagementParams)
+	}
+
+	return value, reason, nil
+}
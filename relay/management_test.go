@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/saveblush/reraw-relay/core/cctx"
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip98"
+)
+
+// authHeader สร้าง header NIP-98 ที่ลงชื่อด้วย sk
+func authHeader(t *testing.T, sk *btcec.PrivateKey, url, method string, body []byte) string {
+	payload := sha256.Sum256(body)
+	evt := &models.Event{
+		Pubkey:    hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey())),
+		CreatedAt: models.Timestamp(utils.Now().Unix()),
+		Kind:      nip98.KindHTTPAuth,
+		Tags: models.Tags{
+			{"u", url},
+			{"method", method},
+			{"payload", hex.EncodeToString(payload[:])},
+		},
+	}
+	evt.ID = evt.GetID()
+
+	hash := sha256.Sum256([]byte(evt.Serialize()))
+	sig, err := schnorr.Sign(sk, hash[:])
+	assert.NoError(t, err)
+	evt.Sig = hex.EncodeToString(sig.Serialize())
+
+	b, err := json.Marshal(evt)
+	assert.NoError(t, err)
+
+	return "Nostr " + base64.StdEncoding.EncodeToString(b)
+}
+
+func TestHandleManagement(t *testing.T) {
+	logger.Log = zap.NewNop().Sugar()
+
+	admin, err := btcec.NewPrivateKey()
+	assert.NoError(t, err)
+	other, err := btcec.NewPrivateKey()
+	assert.NoError(t, err)
+	config.CF.App.Admin.Pubkeys = []string{hex.EncodeToString(schnorr.SerializePubKey(admin.PubKey()))}
+
+	rl := &Relay{
+		cctx:  cctx.New(),
+		nip98: nip98.NewService(),
+		// management api ต้องไม่ถูก reject ของ websocket
+		rejectConnection: []func(r *http.Request) bool{func(r *http.Request) bool { return true }},
+	}
+
+	const url = "http://relay.example.com/"
+	body := []byte(`{"method":"supportedmethods","params":[]}`)
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		status int
+	}{
+		{"valid", authHeader(t, admin, url, "POST", body), body, http.StatusOK},
+		{"wrong url", authHeader(t, admin, "http://other.example.com/", "POST", body), body, http.StatusUnauthorized},
+		{"wrong method", authHeader(t, admin, url, "GET", body), body, http.StatusUnauthorized},
+		{"payload mismatch", authHeader(t, admin, url, "POST", body), []byte(`{"method":"banpubkey","params":[]}`), http.StatusUnauthorized},
+		{"not admin", authHeader(t, other, url, "POST", body), body, http.StatusUnauthorized},
+		{"missing auth", "", body, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", contentTypeManagement)
+			r.Header.Set("Authorization", tt.header)
+			w := httptest.NewRecorder()
+
+			rl.handleRequest(w, r)
+			assert.Equal(t, tt.status, w.Code)
+
+			res := &managementResponse{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+			if tt.status == http.StatusOK {
+				assert.Empty(t, res.Error)
+				assert.NotEmpty(t, res.Result)
+			} else {
+				assert.NotEmpty(t, res.Error)
+			}
+		})
+	}
+}
+
+func TestManageHexParams(t *testing.T) {
+	rl := &Relay{}
+	pubkey := strings.Repeat("ab", 32)
+
+	for _, method := range []string{"banpubkey", "unbanpubkey", "allowpubkey", "unallowpubkey", "banevent"} {
+		for _, value := range []string{"npub1xyz", strings.ToUpper(pubkey), pubkey[:62], ""} {
+			params, _ := json.Marshal([]string{value})
+			req := &managementRequest{Method: method}
+			_ = json.Unmarshal(params, &req.Params)
+
+			_, err := rl.manage(req)
+			assert.ErrorIs(t, err, errManagementParams, "%s %q", method, value)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"github.com/jinzhu/copier"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// info สร้างเอกสาร NIP-11 จาก config และ policy ที่ใช้งานอยู่
+func (rl *Relay) info() *models.RelayInformationDocument {
+	cf := config.CF.Info
+	doc := &models.RelayInformationDocument{
+		Name:           rl.setting(settingRelayName, cf.Name),
+		Description:    rl.setting(settingRelayDescription, cf.Description),
+		Banner:         cf.Banner,
+		Icon:           rl.iconURL(),
+		Pubkey:         cf.Pubkey,
+		Contact:        cf.Contact,
+		SupportedNIPs:  cf.SupportedNIPs,
+		Software:       cf.Software,
+		Version:        cf.Version,
+		PrivacyPolicy:  cf.PrivacyPolicy,
+		TermsOfService: cf.TermsOfService,
+		RelayCountries: cf.RelayCountries,
+		LanguageTags:   cf.LanguageTags,
+		Tags:           cf.Tags,
+		PostingPolicy:  cf.PostingPolicy,
+		PaymentsURL:    cf.PaymentsURL,
+	}
+
+	// fees
+	if cf.Fees != nil {
+		fees := &models.RelayFeesDocument{}
+		copier.Copy(fees, cf.Fees)
+		if !fees.IsEmpty() {
+			doc.Fees = fees
+		}
+	}
+
+	// limitation
+	limitation := &models.RelayLimitationDocument{}
+	if cf.Limitation != nil {
+		copier.Copy(limitation, cf.Limitation)
+	}
+	if limitation.MaxMessageLength == 0 {
+		limitation.MaxMessageLength = int(rl.MessageLengthLimit)
+	}
+	limitation.MinPowDifficulty = rl.policies.MinPowDifficulty()
+
+	// รีเลย์ไม่ได้ตรวจการชำระเงินเอง fees เป็นเพียงข้อมูลประกอบ
+	// payment_required มาจาก config ที่ผู้ดูแลกำหนดเท่านั้น
+
+	// restricted_writes ตาม policy ที่จำกัดผู้เขียนจริง (allowlist, web of trust)
+	limitation.RestrictedWrites = rl.isRestrictedWrites()
+	doc.Limitation = limitation
+
+	// retention
+	// ephemeral events ไม่ถูกจัดเก็บโดยรีเลย์
+	doc.Retention = append(doc.Retention, &models.RelayRetentionDocument{
+		Kinds: []interface{}{[]int{20000, 29999}},
+		Time:  utils.Pointer(int64(0)),
+	})
+	for _, v := range cf.Retention {
+		doc.Retention = append(doc.Retention, &models.RelayRetentionDocument{
+			Kinds: v.Kinds,
+			Time:  v.Time,
+			Count: v.Count,
+		})
+	}
+
+	return doc
+}
+
+// isRestrictedWrites check มี policy ที่จำกัดผู้เขียน event
+func (rl *Relay) isRestrictedWrites() bool {
+	cf := config.CF
+	return (cf.Info.Limitation != nil && cf.Info.Limitation.RestrictedWrites) || cf.WebOfTrust.Enable
+}
@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+const (
+	templateIndex = "index.html"
+
+	// ระยะเวลา cache สถิติของหน้าเว็บ
+	pageStatsTTL = time.Minute
+)
+
+type pageStats struct {
+	ConnectedClients int
+	StoredEvents     int64
+	LastEventAt      time.Time
+}
+
+type pageData struct {
+	Info     *models.RelayInformationDocument
+	URL      string
+	Stats    *pageStats
+	Policies []string
+}
+
+type pageCache struct {
+	mu        sync.Mutex
+	stats     *pageStats
+	updatedAt time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"nipURL": func(nip int) string {
+		return fmt.Sprintf("https://github.com/nostr-protocol/nips/blob/master/%02d.md", nip)
+	},
+}
+
+// loadTemplate โหลด template หน้าเว็บ
+// ถ้ากำหนด template dir และมีไฟล์ index.html จะใช้ไฟล์นั้นแทน template ที่ฝังมา
+func (rl *Relay) loadTemplate() {
+	dir := config.CF.App.TemplateDir
+	if dir != "" {
+		path := filepath.Join(dir, templateIndex)
+		if _, err := os.Stat(path); err == nil {
+			tmpl, err := template.New(templateIndex).Funcs(templateFuncs).ParseFiles(path)
+			if err == nil {
+				rl.template = tmpl
+				return
+			}
+			logger.Log.Errorf("parse template %s error: %s", path, err)
+		}
+	}
+
+	rl.template = template.Must(template.New(templateIndex).Funcs(templateFuncs).ParseFS(templatesFS, "templates/"+templateIndex))
+}
+
+// stats สถิติของรีเลย์ (cache ไว้ตาม pageStatsTTL)
+func (rl *Relay) stats() *pageStats {
+	rl.pageCache.mu.Lock()
+	defer rl.pageCache.mu.Unlock()
+
+	rl.mu.Lock()
+	connected := len(rl.clients)
+	rl.mu.Unlock()
+
+	if rl.pageCache.stats == nil || time.Since(rl.pageCache.updatedAt) > pageStatsTTL {
+		stats := &pageStats{}
+		fetch, err := rl.eventstore.Stats(rl.cctx)
+		if err != nil {
+			logger.Log.Errorf("find stats error: %s", err)
+		} else {
+			stats.StoredEvents = fetch.Count
+			if fetch.LastCreatedAt != nil {
+				stats.LastEventAt = time.Unix(int64(*fetch.LastCreatedAt), 0)
+			}
+		}
+		rl.pageCache.stats = stats
+		rl.pageCache.updatedAt = time.Now()
+	}
+
+	stats := *rl.pageCache.stats
+	stats.ConnectedClients = connected
+
+	return &stats
+}
+
+// policyDescriptions รายการ policy ที่ใช้งานอยู่ สำหรับแสดงบนหน้าเว็บ
+func (rl *Relay) policyDescriptions(info *models.RelayInformationDocument) []string {
+	var res []string
+	if l := info.Limitation; l != nil {
+		if l.AuthRequired {
+			res = append(res, "Authentication (NIP-42) is required")
+		}
+		if l.PaymentRequired {
+			res = append(res, "Payment is required")
+		}
+		if l.RestrictedWrites {
+			res = append(res, "Only approved users may publish events")
+			if config.CF.Allowlist.Inbox {
+				res = append(res, "Events that mention approved users are also accepted")
+			}
+		}
+		if config.CF.WebOfTrust.Enable {
+			res = append(res, fmt.Sprintf("Only users within %d hops of the operator's follow graph may publish events", config.CF.WebOfTrust.Depth))
+		}
+		if l.MinPowDifficulty > 0 {
+			res = append(res, fmt.Sprintf("Events require proof of work (NIP-13) of at least %d bits", l.MinPowDifficulty))
+		}
+		if l.MaxMessageLength > 0 {
+			res = append(res, fmt.Sprintf("Maximum message length is %d bytes", l.MaxMessageLength))
+		}
+		if l.MaxContentLength > 0 {
+			res = append(res, fmt.Sprintf("Maximum event content length is %d characters", l.MaxContentLength))
+		}
+		if l.MaxEventTags > 0 {
+			res = append(res, fmt.Sprintf("Maximum of %d tags per event", l.MaxEventTags))
+		}
+		if l.MaxSubscriptions > 0 {
+			res = append(res, fmt.Sprintf("Maximum of %d subscriptions per connection", l.MaxSubscriptions))
+		}
+		if l.MaxFilters > 0 {
+			res = append(res, fmt.Sprintf("Maximum of %d filters per subscription", l.MaxFilters))
+		}
+		if l.MaxLimit > 0 {
+			res = append(res, fmt.Sprintf("Filter limit is capped at %d events", l.MaxLimit))
+		}
+	}
+
+	res = append(res, "Ephemeral events (kinds 20000-29999) are relayed but never stored")
+
+	if config.CF.App.RateLimit.Enable {
+		res = append(res, fmt.Sprintf("Rate limited to %d messages per second per IP", config.CF.App.RateLimit.Limit))
+	}
+	if config.CF.Blacklist.BlockWords.Enabled {
+		res = append(res, "Events containing blocked words are rejected")
+	}
+
+	return res
+}
+
+// showInfo show html info
+func (rl *Relay) showInfo(w http.ResponseWriter, r *http.Request) {
+	info := rl.info()
+	data := &pageData{
+		Info:     info,
+		URL:      rl.relayURL(r),
+		Stats:    rl.stats(),
+		Policies: rl.policyDescriptions(info),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := rl.template.Execute(w, data)
+	if err != nil {
+		logger.Log.Errorf("render info page error: %s", err)
+	}
+}
@@ -1,26 +1,33 @@
 package relay
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"html/template"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/gorilla/websocket"
-	"github.com/jinzhu/copier"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/time/rate"
 
 	"github.com/saveblush/reraw-relay/core/cctx"
 	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/metrics"
 	"github.com/saveblush/reraw-relay/core/utils"
 	"github.com/saveblush/reraw-relay/core/utils/limiter"
 	"github.com/saveblush/reraw-relay/core/utils/logger"
 	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip13"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip42"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip45"
+	"github.com/saveblush/reraw-relay/pgk/nips/nip98"
 	"github.com/saveblush/reraw-relay/pgk/policies"
 )
 
@@ -42,10 +49,17 @@ var (
 	errInvalidFilter        = errors.New("error: failed to decode filter")
 	errInvalidEvent         = errors.New("error: failed to decode event")
 	errDuplicateEvent       = errors.New("duplicate: already have this event")
+	errDeletedEvent         = errors.New("blocked: this event has been deleted by its author")
+	errVanishedEvent        = errors.New("blocked: the author of this event has requested to vanish")
+	errVanishTarget         = errors.New("invalid: request to vanish does not target this relay")
 	errUnknownCommand       = errors.New("error: unknown command")
 	errSubIDNotFound        = errors.New("error: subscription id not found")
 	errGetSubID             = errors.New("error: received subscription ID is not a string")
-	errrInvalidESubID       = errors.New("invalid: subscription ID must be between 1 and 64 characters")
+	errAuthRequired         = errors.New("auth-required: this relay only serves authenticated users")
+	errClientClosed         = errors.New("error: connection closed")
+	errSlowConsumer         = errors.New("error: connection too slow, outbound queue is full")
+	errRateLimited          = errors.New("rate-limited: relay is busy, please try again later")
+	errShuttingDown         = errors.New("error: relay is shutting down")
 )
 
 type Relay struct {
@@ -53,8 +67,13 @@ type Relay struct {
 	mu       sync.Mutex
 
 	policies         policies.Service
+	nip13            nip13.Service
+	nip42            nip42.Service
+	nip45            nip45.Service
+	nip98            nip98.Service
 	rejectConnection []func(r *http.Request) bool
 	storeEvent       []func(cctx *cctx.Context, evt *models.Event) error
+	rejectFilters    []func(filters *models.Filters) (reject bool, msg string)
 	rejectFilter     []func(filter *models.Filter) (reject bool, msg string)
 	rejectEvent      []func(cctx *cctx.Context, evt *models.Event) (reject bool, msg string)
 
@@ -62,18 +81,40 @@ type Relay struct {
 	register   chan *Client
 	unregister chan *Client
 
+	workers  chan struct{}
+	inflight sync.WaitGroup
+	drainMu  sync.RWMutex
+	draining bool
+
+	// เริ่มปิดตัว readiness ไม่พร้อมแต่ยังรับงานตามปกติ
+	stopping atomic.Bool
+
 	limiter         *limiter.IPRateLimiter
-	limiterBlockIPs map[string]bool
+	limiterBlockIPs map[string]*models.BlockIP
+
+	// ปิดเมื่อรีเลย์ปิด ใช้หยุดงานเบื้องหลัง
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	cctx        *cctx.Context
+	eventstore  eventstore.Service
+	readyChecks []readyCheck
+
+	settingsMu sync.RWMutex
+	settings   map[string]string
 
-	ServiceURL   string
-	Info         *models.RelayInformationDocument
-	faviconBytes []byte
+	ServiceURL string
+	favicon    atomic.Pointer[favicon]
+	template   *template.Template
+	pageCache  pageCache
 
 	HandshakeTimeout   time.Duration
 	WriteWait          time.Duration
 	PongWait           time.Duration
 	PingPeriod         time.Duration
 	MessageLengthLimit int64
+	SendQueueSize      int
+	ReceiveQueueSize   int
 }
 
 // NewRelay new relay
@@ -81,41 +122,67 @@ func NewRelay() *Relay {
 	rl := &Relay{
 		serveMux: &http.ServeMux{},
 		policies: policies.NewService(),
+		nip13:    nip13.NewService(),
+		nip42:    nip42.NewService(),
+		nip45:    nip45.NewService(),
+		nip98:    nip98.NewService(),
 
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 
-		limiterBlockIPs: make(map[string]bool),
+		limiterBlockIPs: make(map[string]*models.BlockIP),
+		settings:        make(map[string]string),
+
+		cctx:       cctx.New(),
+		eventstore: eventstore.NewService(),
+
+		ServiceURL: config.CF.App.ServiceURL,
 
 		HandshakeTimeout:   360 * time.Second,
 		WriteWait:          10 * time.Second,
 		PongWait:           180 * time.Second,
 		PingPeriod:         90 * time.Second,
 		MessageLengthLimit: 0.5 * 1024 * 1024,
+		SendQueueSize:      256,
+		ReceiveQueueSize:   64,
 	}
 
-	// info relay
-	nip11 := &models.RelayInformationDocument{}
-	copier.Copy(nip11, &config.CF.Info)
-	rl.Info = nip11
+	rl.ctx, rl.cancel = context.WithCancel(context.Background())
 
 	// policies event nostr
 	rl.rejectConnection = append(rl.rejectConnection, rl.policies.RejectEmptyHeaderUserAgent)
 	rl.storeEvent = append(rl.storeEvent, rl.policies.StoreBlacklistWithContent)
+	rl.rejectFilters = append(rl.rejectFilters, rl.policies.RejectTooManyFilters, rl.policies.RejectUnsupportedSearch)
 	rl.rejectFilter = append(rl.rejectFilter, rl.policies.RejectEmptyFilters)
 	rl.rejectEvent = append(rl.rejectEvent,
 		rl.policies.RejectValidateEvent,
+		rl.policies.RejectProtectedEvent,
+		rl.policies.RejectEventNotInAllowlist,
+		rl.policies.RejectEventNotInWebOfTrust,
 		rl.policies.RejectValidatePow,
 		rl.policies.RejectValidateTimeStamp,
+		rl.policies.RejectEventTagsLength,
+		rl.policies.RejectEventContentLength,
 		rl.policies.RejectEventWithCharacter,
-		rl.policies.RejectEventFromPubkeyWithBlacklist)
+		rl.policies.RejectEventFromPubkeyWithBlacklist,
+		rl.policies.RejectEventWithBlacklist,
+		rl.policies.RejectEventKindNotAllowed)
+
+	// จำนวน worker สูงสุดทั้งรีเลย์
+	if config.CF.App.Processing.MaxWorkers > 0 {
+		rl.workers = make(chan struct{}, config.CF.App.Processing.MaxWorkers)
+	}
 
 	// retelimit
 	if config.CF.App.RateLimit.Enable {
 		rl.limiter = limiter.NewIPRateLimiter(rate.Limit(config.CF.App.RateLimit.Limit), config.CF.App.RateLimit.Burst)
 	}
 
+	rl.loadBlockIPs()
+	rl.loadSettings()
+	rl.seedAllowlists()
+	rl.loadTemplate()
 	rl.loadFavicon()
 	go rl.ready()
 
@@ -125,43 +192,162 @@ func NewRelay() *Relay {
 func (rl *Relay) Serve() *http.ServeMux {
 	mux := rl.serveMux
 	mux.HandleFunc("/favicon.ico", rl.handleFavicon)
+	mux.HandleFunc("/healthz", rl.handleHealthz)
+	mux.HandleFunc("/readyz", rl.handleReadyz)
+	mux.HandleFunc("/admin/blockips", rl.handleAdminBlockIPs)
+	if config.CF.App.Metrics.Enable {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
 	mux.HandleFunc("/", rl.handleRequest)
 
 	return mux
 }
 
 func (rl *Relay) ready() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
 	for {
 		select {
+		case <-ticker.C:
+			rl.cleanupLimiter()
+
 		case client := <-rl.register:
+			rl.mu.Lock()
 			rl.clients[client] = true
+			rl.mu.Unlock()
+			metrics.ConnectedClients.Inc()
 			logger.Log.Infof("[connected] %s", client.IP())
 
 		case client := <-rl.unregister:
+			rl.mu.Lock()
 			if _, ok := rl.clients[client]; ok {
 				delete(rl.clients, client)
+				metrics.ConnectedClients.Dec()
 				logger.Log.Infof("[disconnect] %s", client.Info())
 			}
+			rl.mu.Unlock()
 		}
 	}
 }
 
+// acquireWorker จอง worker สำหรับประมวลผลข้อความ
+func (rl *Relay) acquireWorker() error {
+	rl.drainMu.RLock()
+	defer rl.drainMu.RUnlock()
+
+	if rl.draining {
+		return errShuttingDown
+	}
+
+	if rl.workers != nil {
+		select {
+		case rl.workers <- struct{}{}:
+		default:
+			return errRateLimited
+		}
+	}
+	rl.inflight.Add(1)
+
+	return nil
+}
+
+// releaseWorker คืน worker
+func (rl *Relay) releaseWorker() {
+	if rl.workers != nil {
+		<-rl.workers
+	}
+	rl.inflight.Done()
+}
+
+// isDraining check รีเลย์กำลังปิดตัว
+func (rl *Relay) isDraining() bool {
+	rl.drainMu.RLock()
+	defer rl.drainMu.RUnlock()
+
+	return rl.draining
+}
+
+// BeginDrain แจ้ง readiness ว่าไม่พร้อม (/readyz ตอบ 503) ให้ load balancer หยุดส่ง connection ใหม่
+// ยังประมวลผลข้อความตามปกติจนกว่าจะเรียก Drain
+func (rl *Relay) BeginDrain() {
+	rl.stopping.Store(true)
+}
+
+// Drain ปิดรีเลย์แบบรอให้งานที่ค้างอยู่เสร็จ
+// แจ้ง CLOSED ทุก subscription, รอ EVENT ที่กำลังประมวลผลจนเสร็จหรือครบเวลา ctx แล้วปิดการเชื่อมต่อ
+func (rl *Relay) Drain(ctx context.Context) error {
+	rl.BeginDrain()
+
+	rl.drainMu.Lock()
+	rl.draining = true
+	rl.drainMu.Unlock()
+
+	rl.mu.Lock()
+	clients := make([]*Client, 0, len(rl.clients))
+	for client := range rl.clients {
+		clients = append(clients, client)
+	}
+	rl.mu.Unlock()
+
+	for _, client := range clients {
+		for _, subID := range client.closeSubscriptions() {
+			_ = client.send([]interface{}{"CLOSED", subID, errShuttingDown.Error()})
+		}
+		_ = client.send([]interface{}{"NOTICE", errShuttingDown.Error()})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rl.inflight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		logger.Log.Warnf("drain relay timeout: %s", err)
+	}
+
+	// รอให้ writer ส่งข้อความที่ค้างในคิว
+	for _, client := range clients {
+		client.flush(ctx)
+	}
+
+	_ = rl.CloseRelay()
+
+	return err
+}
+
 // CloseRelay close relay
 func (rl *Relay) CloseRelay() error {
+	if rl.cancel != nil {
+		rl.cancel()
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	for c := range rl.clients {
 		c.conn.WriteControl(websocket.CloseMessage, nil, time.Now().Add(time.Second))
-		c.conn.Close()
+		c.close()
 	}
 	clear(rl.clients)
+	metrics.ConnectedClients.Set(0)
 
 	return nil
 }
 
 // handleRequest handle request
 func (rl *Relay) handleRequest(w http.ResponseWriter, r *http.Request) {
+	// management api (NIP-86) ยืนยันตัวตนด้วย NIP-98 ไม่ต้องผ่าน reject ของ websocket
+	if r.Method == http.MethodPost && isManagementRequest(r) {
+		rl.handleManagement(w, r)
+		return
+	}
+
 	// check reject
 	for _, rejectFunc := range rl.rejectConnection {
 		if rejectFunc(r) {
@@ -181,18 +367,23 @@ func (rl *Relay) handleRequest(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.Header.Get("Accept"), "application/nostr+json") {
 			rl.showNIP11(w)
 		} else {
-			rl.showInfo(w)
+			rl.showInfo(w, r)
 		}
 	}
 }
 
 // handleWebsocket handle websocket
 func (rl *Relay) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	if rl.isDraining() {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
 	// limiter block ip
 	ip := utils.GetIP(r)
-	_, exists := rl.limiterBlockIPs[ip]
-	if exists {
+	if rl.isBlockedIP(ip) {
 		logger.Log.Warnf("limiter block ip: %s", ip)
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -208,22 +399,52 @@ func (rl *Relay) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// client
+	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
-		relay:       rl,
-		conn:        conn,
-		ip:          ip,
-		userAgent:   utils.GetUserAgent(r),
-		connectedAt: utils.Now(),
+		relay:         rl,
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
+		inbox:         make(chan []byte, rl.ReceiveQueueSize),
+		outbound:      make(chan []byte, rl.SendQueueSize),
+		done:          make(chan struct{}),
+		ip:            ip,
+		userAgent:     utils.GetUserAgent(r),
+		connectedAt:   utils.Now(),
+		subscriptions: make(map[string]*subscription),
+		relayURL:      rl.relayURL(r),
+		challenge:     rl.nip42.Challenge(),
 	}
 	client.relay.register <- client
 
+	// เขียนข้อความไปยัง client
+	go client.writer()
+
+	// ส่ง challenge สำหรับยืนยันตัวตน (NIP-42)
+	_ = client.send([]interface{}{"AUTH", client.challenge})
+
 	// อ่านข้อความ
 	client.reader()
 }
 
+// relayURL url ของรีเลย์ที่ client เชื่อมต่อเข้ามา
+func (rl *Relay) relayURL(r *http.Request) string {
+	if rl.ServiceURL != "" {
+		return rl.ServiceURL
+	}
+
+	// X-Forwarded-Proto เชื่อเฉพาะจาก proxy ที่เชื่อถือได้
+	scheme := "ws"
+	if r.TLS != nil || (utils.IsTrustedProxyRequest(r) && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "wss"
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+}
+
 // showNIP11 show nip11 info
 func (rl *Relay) showNIP11(w http.ResponseWriter) {
-	b, err := json.Marshal(&rl.Info)
+	b, err := json.Marshal(rl.info())
 	if err != nil {
 		fmt.Fprintf(w, "{}")
 		return
@@ -234,47 +455,3 @@ func (rl *Relay) showNIP11(w http.ResponseWriter) {
 
 	_, _ = w.Write(b)
 }
-
-// showInfo show html info
-func (rl *Relay) showInfo(w http.ResponseWriter) {
-	supportedNIPs := rl.Info.SupportedNIPs
-	arrSupportedNIPs := make([]string, len(supportedNIPs))
-	for i, v := range supportedNIPs {
-		arrSupportedNIPs[i] = fmt.Sprintf("%v", v)
-	}
-
-	var str []string
-	str = append(str, fmt.Sprintf("Name: %s", rl.Info.Name))
-	str = append(str, fmt.Sprintf("Description: %s", rl.Info.Description))
-	str = append(str, fmt.Sprintf("PubKey: %s", rl.Info.Pubkey))
-	str = append(str, fmt.Sprintf("Contact: %s", rl.Info.Contact))
-	str = append(str, fmt.Sprintf("SupportedNIPs: %s", strings.Join(arrSupportedNIPs, ", ")))
-	str = append(str, fmt.Sprintf("Software: %s", rl.Info.Software))
-	str = append(str, fmt.Sprintf("Version: %s", rl.Info.Version))
-
-	_, _ = w.Write([]byte(strings.Join(str, "\n")))
-}
-
-// handleFavicon handle favicon
-func (rl *Relay) handleFavicon(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "image/x-icon")
-	w.Header().Set("Cache-Control", "public, max-age=7776000")
-
-	_, _ = w.Write(rl.faviconBytes)
-}
-
-// LoadFavicon load favicon
-func (rl *Relay) loadFavicon() {
-	if rl.Info.Icon == "" {
-		return
-	}
-
-	resp, err := http.Get(rl.Info.Icon)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var buffer bytes.Buffer
-		if _, err = io.Copy(&buffer, resp.Body); err != nil {
-			return
-		}
-		rl.faviconBytes = buffer.Bytes()
-	}
-}
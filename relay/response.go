@@ -1,23 +1,13 @@
 package relay
 
 import (
-	"github.com/goccy/go-json"
-	"github.com/gorilla/websocket"
-
+	"github.com/saveblush/reraw-relay/core/metrics"
 	"github.com/saveblush/reraw-relay/models"
 )
 
 // websocket response
 func (s *service) response(msg interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	b, err := json.Marshal(&msg)
-	if err != nil {
-		return err
-	}
-
-	return s.client.conn.WriteMessage(websocket.TextMessage, b)
+	return s.client.send(msg)
 }
 
 func (s *service) responseEvent(subID string, evt *models.Event) error {
@@ -30,6 +20,8 @@ func (s *service) responseEvent(subID string, evt *models.Event) error {
 }
 
 func (s *service) responseOK(eventID string, isSuccess bool, message string) error {
+	metrics.ObserveOK(isSuccess, message)
+
 	err := s.response([]interface{}{"OK", eventID, isSuccess, message})
 	if err != nil {
 		return err
@@ -38,7 +30,7 @@ func (s *service) responseOK(eventID string, isSuccess bool, message string) err
 	return nil
 }
 
-func (s *service) responseCount(subID string, count *int64) error {
+func (s *service) responseCount(subID string, count *models.CountResult) error {
 	err := s.response([]interface{}{"COUNT", subID, count})
 	if err != nil {
 		return err
@@ -75,3 +67,25 @@ func (s *service) responseError(message string) error {
 
 	return nil
 }
+
+// responseSubIDError แจ้ง subscription id ไม่ถูกต้อง
+// ถ้าอ่าน id ไม่ได้จะแจ้งด้วย NOTICE
+func (s *service) responseSubIDError(subID string, err error) error {
+	if subID == "" {
+		return s.responseError(err.Error())
+	}
+
+	return s.responseClosed(subID, err.Error())
+}
+
+// return เมื่อรีเลย์ไม่สามารถรับงานเพิ่มได้
+func (s *service) responseRejected(cmd, id, message string) error {
+	switch cmd {
+	case "EVENT":
+		return s.responseOK(id, false, message)
+	case "REQ", "COUNT":
+		return s.responseClosed(id, message)
+	default:
+		return s.responseError(message)
+	}
+}
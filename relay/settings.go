@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+)
+
+// key ของค่าที่แก้ไขได้ผ่าน management api (NIP-86)
+const (
+	settingRelayName        = "relay_name"
+	settingRelayDescription = "relay_description"
+)
+
+// loadSettings โหลดค่าที่แก้ไขไว้จาก database
+func (rl *Relay) loadSettings() {
+	fetch, err := rl.eventstore.FindSettings(rl.cctx)
+	if err != nil {
+		logger.Log.Errorf("load settings error: %s", err)
+		return
+	}
+
+	rl.settingsMu.Lock()
+	defer rl.settingsMu.Unlock()
+
+	for _, v := range fetch {
+		rl.settings[v.Key] = v.Value
+	}
+}
+
+// setting ค่าที่แก้ไขไว้ ถ้าไม่มีใช้ค่าจาก config
+func (rl *Relay) setting(key, fallback string) string {
+	rl.settingsMu.RLock()
+	defer rl.settingsMu.RUnlock()
+
+	if v, ok := rl.settings[key]; ok {
+		return v
+	}
+
+	return fallback
+}
+
+// setSetting บันทึกค่า
+func (rl *Relay) setSetting(key, value string) error {
+	err := rl.eventstore.InsertSetting(rl.cctx, &models.Setting{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+
+	rl.settingsMu.Lock()
+	defer rl.settingsMu.Unlock()
+
+	rl.settings[key] = value
+
+	return nil
+}
+
+// seedAllowlists เพิ่ม pubkey จาก config เข้า allowlist
+func (rl *Relay) seedAllowlists() {
+	err := rl.policies.SeedAllowlists(rl.cctx)
+	if err != nil {
+		logger.Log.Errorf("seed allowlists error: %s", err)
+	}
+}
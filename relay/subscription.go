@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/saveblush/reraw-relay/core/config"
+	"github.com/saveblush/reraw-relay/core/metrics"
+	"github.com/saveblush/reraw-relay/core/utils/logger"
+	"github.com/saveblush/reraw-relay/models"
+	"github.com/saveblush/reraw-relay/pgk/eventstore"
+)
+
+type subscription struct {
+	*models.Subscription
+	cancel context.CancelFunc
+}
+
+// setSubscription เพิ่ม subscription ของ client
+// ถ้ามี id เดิมอยู่แล้วจะยกเลิกตัวเดิมและแทนที่ด้วยตัวใหม่
+func (client *Client) setSubscription(subID string, filters models.Filters) (context.Context, error) {
+	client.subMu.Lock()
+	defer client.subMu.Unlock()
+
+	previous, exists := client.subscriptions[subID]
+	if exists {
+		previous.cancel()
+	} else {
+		max := config.CF.Info.Limitation.MaxSubscriptions
+		if max > 0 && len(client.subscriptions) >= max {
+			return nil, fmt.Errorf("restricted: maximum of %d subscriptions per connection", max)
+		}
+		metrics.ActiveSubscriptions.Inc()
+	}
+
+	ctx, cancel := context.WithCancel(client.ctx)
+	client.subscriptions[subID] = &subscription{
+		Subscription: &models.Subscription{ID: subID, Filters: filters},
+		cancel:       cancel,
+	}
+
+	return ctx, nil
+}
+
+// removeSubscription ยกเลิกและลบ subscription ของ client
+func (client *Client) removeSubscription(subID string) bool {
+	client.subMu.Lock()
+	defer client.subMu.Unlock()
+
+	sub, exists := client.subscriptions[subID]
+	if !exists {
+		return false
+	}
+
+	sub.cancel()
+	delete(client.subscriptions, subID)
+	metrics.ActiveSubscriptions.Dec()
+
+	return true
+}
+
+// cancelSubscription ยกเลิก query ของ subscription โดยยังไม่ลบออก
+func (client *Client) cancelSubscription(subID string) {
+	client.subMu.RLock()
+	defer client.subMu.RUnlock()
+
+	if sub, exists := client.subscriptions[subID]; exists {
+		sub.cancel()
+	}
+}
+
+// closeSubscriptions ยกเลิก subscription ทั้งหมดของ client
+func (client *Client) closeSubscriptions() []string {
+	client.subMu.Lock()
+	defer client.subMu.Unlock()
+
+	subIDs := make([]string, 0, len(client.subscriptions))
+	for id, sub := range client.subscriptions {
+		sub.cancel()
+		delete(client.subscriptions, id)
+		subIDs = append(subIDs, id)
+		metrics.ActiveSubscriptions.Dec()
+	}
+
+	return subIDs
+}
+
+// matchSubscriptions หา subscription id ที่ filter ตรงกับ event
+func (client *Client) matchSubscriptions(evt *models.Event, metadata func() *models.Event) []string {
+	client.subMu.RLock()
+	defer client.subMu.RUnlock()
+
+	var subIDs []string
+	for id, sub := range client.subscriptions {
+		if sub.Filters.MatchWith(evt, metadata) {
+			subIDs = append(subIDs, id)
+		}
+	}
+
+	return subIDs
+}
+
+// broadcast ส่ง event ใหม่ไปยังทุก client ที่มี subscription ตรงกัน
+func (rl *Relay) broadcast(evt *models.Event) {
+	rl.mu.Lock()
+	clients := make([]*Client, 0, len(rl.clients))
+	for client := range rl.clients {
+		clients = append(clients, client)
+	}
+	rl.mu.Unlock()
+
+	metadata := rl.metadataLoader(evt.Pubkey)
+	for _, client := range clients {
+		for _, subID := range client.matchSubscriptions(evt, metadata) {
+			err := client.send([]interface{}{"EVENT", subID, evt})
+			if err != nil {
+				logger.Log.Warnf("broadcast to %s error: %s", client.IP(), err)
+			}
+		}
+	}
+}
+
+// metadataLoader โหลด metadata (kind 0) ล่าสุดของ pubkey เมื่อถูกเรียกครั้งแรก
+// ใช้กับ search extension domain (NIP-50)
+func (rl *Relay) metadataLoader(pubkey string) func() *models.Event {
+	return sync.OnceValue(func() *models.Event {
+		fetch, err := rl.eventstore.FindAll(rl.cctx, &eventstore.Request{
+			NostrFilter: &models.Filter{Authors: []string{pubkey}, Kinds: []int{0}, Limit: 1},
+		})
+		if err != nil {
+			logger.Log.Warnf("find metadata %s error: %s", pubkey, err)
+			return nil
+		}
+		if len(fetch) == 0 {
+			return nil
+		}
+
+		return fetch[0]
+	})
+}